@@ -0,0 +1,94 @@
+// Command statement exports the ledger statement of an account for a period.
+//
+// USAGE:
+// go run ./cmd/statement -account jim -from 2024-10-01 -to 2024-11-01 [-asset USD] [-format csv] [-out statement.csv]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/joho/godotenv/autoload"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"sphere-homework/app/repository"
+	"sphere-homework/app/services"
+)
+
+func main() {
+	account := flag.String("account", "", "account name")
+	from := flag.String("from", "", "start of the period (inclusive), YYYY-MM-DD or RFC3339")
+	to := flag.String("to", "", "end of the period (exclusive), YYYY-MM-DD or RFC3339")
+	asset := flag.String("asset", "", "only export this asset")
+	format := flag.String("format", "csv", "csv or json")
+	out := flag.String("out", "", "output file, defaults to stdout")
+	dbUrl := flag.String("db-url", os.Getenv("DB_URL"), "database url")
+	flag.Parse()
+
+	if *account == "" || (*format != "csv" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fromTime, err := services.ParseStatementTime(*from)
+	if err != nil {
+		exit("invalid from: %v", err)
+	}
+
+	toTime, err := services.ParseStatementTime(*to)
+	if err != nil {
+		exit("invalid to: %v", err)
+	}
+
+	ctx := context.Background()
+	logger, _ := zap.NewProduction()
+
+	pool, err := pgxpool.New(ctx, *dbUrl)
+	if err != nil {
+		exit("failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	assetRepository := repository.NewAssetRepository(pool, ctx)
+	ledgerRepository := repository.NewLedgerRepository(pool, ctx, logger, repository.NewAssetRegistry(&assetRepository))
+
+	var assetFilter *string
+	if *asset != "" {
+		assetFilter = asset
+	}
+
+	statement, err := ledgerRepository.GetStatement(*account, fromTime, toTime, assetFilter)
+	if err != nil {
+		exit("failed to fetch statement: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			exit("failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(services.ToStatementResponse(*statement))
+	} else {
+		err = services.WriteStatementCSV(w, *statement)
+	}
+
+	if err != nil {
+		exit("failed to write statement: %v", err)
+	}
+}
+
+func exit(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
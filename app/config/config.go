@@ -1,17 +1,40 @@
 package config
 
 import (
+	"fmt"
+	"github.com/shopspring/decimal"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Port                           int
-	DbUrl                          string
-	KafkaBootstrapServers          string
-	RedisUrl                       string
-	TransferOutboxPollFrequencySec int
-	PoolRebalancerPollFreqnecySec  int
+	Port                            int
+	DbUrl                           string
+	KafkaBootstrapServers           string
+	RedisUrl                        string
+	TransferOutboxPollFrequencySec  int
+	PoolRebalancerPollFreqnecySec   int
+	TransferMaxAmount               decimal.Decimal // maximum amount per transfer in the source asset, zero means no limit
+	IdempotencyKeyTtlSec            int             // how long a transfer idempotency key is remembered
+	SettlementTimeoutSec            int             // sent transfers not confirmed by the rail within this time are failed and reversed
+	SettlementSweepFrequencySec     int
+	EventRelayPollFrequencyMs       int // how often the event outbox is polled for events to publish
+	TransferLockLeaseSec            int // how long an outbox processor may hold a transfer before its lock is reclaimed
+	TransferLockReaperFrequencySec  int
+	TransferHoldExpirySec           int            // holds of transfers that did not reach the transfer outbox within this time are released
+	TransferWorkersPerAsset         int            // number of outbox workers processing the transfers to each destination asset
+	TransferAssetWorkers            map[string]int // overrides TransferWorkersPerAsset for some destination assets
+	TransferMaxAttempts             int            // transfers failing with transient errors are failed after this many attempts
+	TransferRetryBaseDelayMs        int            // backoff after the first failed attempt, doubled for every further attempt
+	TransferRetryMaxDelayMs         int
+	KafkaDeliveryTimeoutMs          int            // how long the kafka producer tries to deliver a message before reporting it failed
+	ShutdownTimeoutSec              int            // how long a shutdown may take to drain in-flight work before the process exits
+	SimulatedRailSettlementDelaySec int            // how long the simulated payout rail takes to complete or fail a payout
+	SimulatedRailRejectionRate      float64        // ratio of payouts the simulated rail rejects on submission, between 0 and 1
+	SimulatedRailFailureRate        float64        // ratio of payouts the simulated rail fails once settled
+	SimulatedRailTimeoutRate        float64        // ratio of payouts the simulated rail never settles
+	SimulatedRailSubmitLatencyMs    map[string]int // how long the simulated payout rail of a destination asset takes to accept a payout
 }
 
 func NewConfig() Config {
@@ -30,12 +53,133 @@ func NewConfig() Config {
 
 	poolRebalancerPollFreqnecySec, err := strconv.ParseInt(os.Getenv("POOL_REBALANCER_POLL_FREQUENCY_SEC"), 10, 64)
 
+	transferMaxAmount := decimal.Zero
+	if value := os.Getenv("TRANSFER_MAX_AMOUNT"); value != "" {
+		transferMaxAmount, err = decimal.NewFromString(value)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	idempotencyKeyTtlSec := getOptionalInt("IDEMPOTENCY_KEY_TTL_SEC", 24*60*60)
+	settlementTimeoutSec := getOptionalInt("SETTLEMENT_TIMEOUT_SEC", 10*60)
+	settlementSweepFrequencySec := getOptionalInt("SETTLEMENT_SWEEP_FREQUENCY_SEC", 30)
+	eventRelayPollFrequencyMs := getOptionalInt("EVENT_RELAY_POLL_FREQUENCY_MS", 500)
+	transferLockLeaseSec := getOptionalInt("TRANSFER_LOCK_LEASE_SEC", 60)
+	transferLockReaperFrequencySec := getOptionalInt("TRANSFER_LOCK_REAPER_FREQUENCY_SEC", 30)
+	transferHoldExpirySec := getOptionalInt("TRANSFER_HOLD_EXPIRY_SEC", 10*60)
+	transferWorkersPerAsset := getOptionalInt("TRANSFER_WORKERS_PER_ASSET", 4)
+
+	transferMaxAttempts := getOptionalInt("TRANSFER_MAX_ATTEMPTS", 5)
+	transferRetryBaseDelayMs := getOptionalInt("TRANSFER_RETRY_BASE_DELAY_MS", 1000)
+	transferRetryMaxDelayMs := getOptionalInt("TRANSFER_RETRY_MAX_DELAY_MS", 5*60*1000)
+	shutdownTimeoutSec := getOptionalInt("SHUTDOWN_TIMEOUT_SEC", 30)
+	kafkaDeliveryTimeoutMs := getOptionalInt("KAFKA_DELIVERY_TIMEOUT_MS", 10000)
+
+	transferAssetWorkers, err := parseAssetInts(os.Getenv("TRANSFER_ASSET_WORKERS"))
+	if err != nil {
+		panic(err)
+	}
+
+	simulatedRailSettlementDelaySec := getOptionalInt("SIMULATED_RAIL_SETTLEMENT_DELAY_SEC", 2)
+	simulatedRailRejectionRate := getOptionalFloat("SIMULATED_RAIL_REJECTION_RATE", 0)
+	simulatedRailFailureRate := getOptionalFloat("SIMULATED_RAIL_FAILURE_RATE", 0)
+	simulatedRailTimeoutRate := getOptionalFloat("SIMULATED_RAIL_TIMEOUT_RATE", 0)
+
+	simulatedRailSubmitLatencyMs, err := parseAssetInts(os.Getenv("SIMULATED_RAIL_SUBMIT_LATENCY_MS"))
+	if err != nil {
+		panic(err)
+	}
+
 	return Config{
-		Port:                           i,
-		DbUrl:                          dbUrl,
-		KafkaBootstrapServers:          kafkaBootstrapServers,
-		RedisUrl:                       redisUrl,
-		TransferOutboxPollFrequencySec: int(transferOutboxPollFrequencySec),
-		PoolRebalancerPollFreqnecySec:  int(poolRebalancerPollFreqnecySec),
+		Port:                            i,
+		DbUrl:                           dbUrl,
+		KafkaBootstrapServers:           kafkaBootstrapServers,
+		RedisUrl:                        redisUrl,
+		TransferOutboxPollFrequencySec:  int(transferOutboxPollFrequencySec),
+		PoolRebalancerPollFreqnecySec:   int(poolRebalancerPollFreqnecySec),
+		TransferMaxAmount:               transferMaxAmount,
+		IdempotencyKeyTtlSec:            idempotencyKeyTtlSec,
+		SettlementTimeoutSec:            settlementTimeoutSec,
+		SettlementSweepFrequencySec:     settlementSweepFrequencySec,
+		EventRelayPollFrequencyMs:       eventRelayPollFrequencyMs,
+		TransferLockLeaseSec:            transferLockLeaseSec,
+		TransferLockReaperFrequencySec:  transferLockReaperFrequencySec,
+		TransferHoldExpirySec:           transferHoldExpirySec,
+		TransferWorkersPerAsset:         transferWorkersPerAsset,
+		TransferAssetWorkers:            transferAssetWorkers,
+		TransferMaxAttempts:             transferMaxAttempts,
+		TransferRetryBaseDelayMs:        transferRetryBaseDelayMs,
+		TransferRetryMaxDelayMs:         transferRetryMaxDelayMs,
+		KafkaDeliveryTimeoutMs:          kafkaDeliveryTimeoutMs,
+		ShutdownTimeoutSec:              shutdownTimeoutSec,
+		SimulatedRailSettlementDelaySec: simulatedRailSettlementDelaySec,
+		SimulatedRailRejectionRate:      simulatedRailRejectionRate,
+		SimulatedRailFailureRate:        simulatedRailFailureRate,
+		SimulatedRailTimeoutRate:        simulatedRailTimeoutRate,
+		SimulatedRailSubmitLatencyMs:    simulatedRailSubmitLatencyMs,
+	}
+}
+
+// TransferWorkers returns the number of outbox workers processing the transfers to the destination asset
+func (c Config) TransferWorkers(asset string) int {
+	if workers, ok := c.TransferAssetWorkers[asset]; ok {
+		return workers
 	}
+
+	return c.TransferWorkersPerAsset
+}
+
+// parseAssetInts parses a comma separated list of asset=value pairs of non-negative integers, e.g. "USD=8,JPY=2"
+func parseAssetInts(value string) (map[string]int, error) {
+	assetInts := map[string]int{}
+	if value == "" {
+		return assetInts, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		asset, number, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid asset value: %s", pair)
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for %s: %s", asset, number)
+		}
+
+		assetInts[strings.ToUpper(asset)] = n
+	}
+
+	return assetInts, nil
+}
+
+// getOptionalInt returns the integer value of the environment variable, or the default if it is not set
+func getOptionalInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
+// getOptionalFloat returns the float value of the environment variable, or the default if it is not set
+func getOptionalFloat(name string, defaultValue float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
 }
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAssetInts(t *testing.T) {
+	workers, err := parseAssetInts("USD=8, jpy=2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"USD": 8, "JPY": 2}, workers)
+
+	workers, err = parseAssetInts("")
+	assert.NoError(t, err)
+	assert.Empty(t, workers)
+
+	_, err = parseAssetInts("USD")
+	assert.Error(t, err)
+
+	_, err = parseAssetInts("USD=many")
+	assert.Error(t, err)
+}
+
+func TestTransferWorkers(t *testing.T) {
+	config := Config{
+		TransferWorkersPerAsset: 4,
+		TransferAssetWorkers:    map[string]int{"USD": 8},
+	}
+
+	assert.Equal(t, 8, config.TransferWorkers("USD"))
+	assert.Equal(t, 4, config.TransferWorkers("EUR"))
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+type CreateAssetRequest struct {
+	Code         string `json:"code"`
+	MinorUnits   int32  `json:"minor_units"`
+	RoundingMode string `json:"rounding_mode"`
+}
+
+type AssetResponse struct {
+	Code         string    `json:"code"`
+	MinorUnits   int32     `json:"minor_units"`
+	RoundingMode string    `json:"rounding_mode"`
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ListAssetsResponse struct {
+	Assets []AssetResponse `json:"assets"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/shopspring/decimal"
+
+type AccountBalanceResponse struct {
+	Asset           string          `json:"asset"`
+	Balance         decimal.Decimal `json:"balance"`
+	Held            decimal.Decimal `json:"held"`
+	PendingOutgoing decimal.Decimal `json:"pending_outgoing"`
+	Available       decimal.Decimal `json:"available"`
+}
+
+type AccountBalancesResponse struct {
+	Account  string                   `json:"account"`
+	Balances []AccountBalanceResponse `json:"balances"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+type DeadLetterHeaderResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type DeadLetterResponse struct {
+	Id         int64                      `json:"id"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	Consumer   string                     `json:"consumer"`
+	Error      string                     `json:"error"`
+	Topic      string                     `json:"topic"`
+	Partition  int32                      `json:"partition"`
+	Offset     int64                      `json:"offset"`
+	Key        string                     `json:"key"`
+	Payload    string                     `json:"payload"`
+	Headers    []DeadLetterHeaderResponse `json:"headers"`
+	ReplayedAt *time.Time                 `json:"replayed_at,omitempty"`
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []DeadLetterResponse `json:"dead_letters"`
+	NextCursor  string               `json:"next_cursor,omitempty"`
+}
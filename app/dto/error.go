@@ -1,5 +1,33 @@
 package dto
 
+type ErrorCode string
+
+const (
+	InvalidRequestErrorCode         ErrorCode = "invalid_request"
+	InvalidAmountErrorCode          ErrorCode = "invalid_amount"
+	AmountLimitExceededErrorCode    ErrorCode = "amount_limit_exceeded"
+	UnknownAssetErrorCode           ErrorCode = "unknown_asset"
+	AssetNotFoundErrorCode          ErrorCode = "asset_not_found"
+	AssetExistsErrorCode            ErrorCode = "asset_already_exists"
+	DisabledAssetErrorCode          ErrorCode = "asset_disabled"
+	SameSenderRecipientErrorCode    ErrorCode = "same_sender_recipient"
+	UnknownSenderErrorCode          ErrorCode = "unknown_sender"
+	InsufficientBalanceErrorCode    ErrorCode = "insufficient_balance"
+	RateUnavailableErrorCode        ErrorCode = "rate_unavailable"
+	FeeUnavailableErrorCode         ErrorCode = "fee_unavailable"
+	IdempotencyKeyConflictErrorCode ErrorCode = "idempotency_key_conflict"
+	TransferNotFoundErrorCode       ErrorCode = "transfer_not_found"
+	AccountNotFoundErrorCode        ErrorCode = "account_not_found"
+	TransferNotCancellableErrorCode ErrorCode = "transfer_not_cancellable"
+	TransferNotSettleableErrorCode  ErrorCode = "transfer_not_settleable"
+	TransferNotReversibleErrorCode  ErrorCode = "transfer_not_reversible"
+	ReversalNotCoveredErrorCode     ErrorCode = "reversal_not_covered"
+	DeadLetterNotFoundErrorCode     ErrorCode = "dead_letter_not_found"
+	DeadLetterReplayedErrorCode     ErrorCode = "dead_letter_already_replayed"
+	InternalErrorCode               ErrorCode = "internal_error"
+)
+
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
 }
@@ -0,0 +1,11 @@
+package dto
+
+const (
+	HealthyStatus   = "ok"
+	UnhealthyStatus = "unhealthy"
+)
+
+type HealthResponse struct {
+	Status   string            `json:"status"`
+	Services map[string]string `json:"services"` // status of each background service, or the reason it is unhealthy
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "github.com/shopspring/decimal"
+
+type ReverseTransferRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReversalEntryResponse struct {
+	Account string          `json:"account"`
+	Asset   string          `json:"asset"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+type ReversalResponse struct {
+	Transfer TransferStatusResponse  `json:"transfer"`
+	Reason   string                  `json:"reason"`
+	Entries  []ReversalEntryResponse `json:"entries"`
+}
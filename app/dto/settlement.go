@@ -0,0 +1,10 @@
+package dto
+
+import "github.com/google/uuid"
+
+// SettlementCallbackRequest is sent by the payout rail once it confirmed (COMPLETED) or rejected (FAILED) a transfer
+type SettlementCallbackRequest struct {
+	TransferId    uuid.UUID `json:"transfer_id"`
+	Status        string    `json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type StatementEntryResponse struct {
+	CreatedAt      time.Time       `json:"created_at"`
+	TransferId     uuid.UUID       `json:"transfer_id"`
+	EntryType      string          `json:"entry_type"`
+	Asset          string          `json:"asset"`
+	Amount         decimal.Decimal `json:"amount"`
+	RunningBalance decimal.Decimal `json:"running_balance"`
+}
+
+type AssetStatementResponse struct {
+	Asset          string                   `json:"asset"`
+	OpeningBalance decimal.Decimal          `json:"opening_balance"`
+	ClosingBalance decimal.Decimal          `json:"closing_balance"`
+	Entries        []StatementEntryResponse `json:"entries"`
+}
+
+type StatementResponse struct {
+	Account string                   `json:"account"`
+	From    time.Time                `json:"from"`
+	To      time.Time                `json:"to"`
+	Assets  []AssetStatementResponse `json:"assets"`
+}
@@ -1,15 +1,45 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
 
 type TransferRequest struct {
-	FromAsset string  `json:"from_asset"`
-	ToAsset   string  `json:"to_asset"`
-	Amount    float64 `json:"amount"`
-	Sender    string  `json:"sender"`
-	Recipient string  `json:"recipient"`
+	FromAsset string          `json:"from_asset"`
+	ToAsset   string          `json:"to_asset"`
+	Amount    decimal.Decimal `json:"amount"`
+	Sender    string          `json:"sender"`
+	Recipient string          `json:"recipient"`
 }
 
 type TransferResponse struct {
 	TransferId uuid.UUID `json:"transfer_id"`
 }
+
+// AcceptedTransferStatus is reported for transfers that were accepted but are not yet in the outbox
+const AcceptedTransferStatus = "ACCEPTED"
+
+type TransferStatusResponse struct {
+	TransferId      uuid.UUID        `json:"transfer_id"`
+	Status          string           `json:"status"`
+	FromAsset       string           `json:"from_asset"`
+	ToAsset         string           `json:"to_asset"`
+	Sender          string           `json:"sender"`
+	Recipient       string           `json:"recipient"`
+	RequestedAmount decimal.Decimal  `json:"requested_amount"`
+	Fee             *decimal.Decimal `json:"fee,omitempty"`
+	NetAmount       *decimal.Decimal `json:"net_amount,omitempty"`
+	SentAmount      *decimal.Decimal `json:"sent_amount,omitempty"`
+	Rate            decimal.Decimal  `json:"rate"`
+	CreatedAt       time.Time        `json:"created_at"`
+	SentAt          *time.Time       `json:"sent_at,omitempty"`
+	SettledAt       *time.Time       `json:"settled_at,omitempty"`
+	FailureReason   *string          `json:"failure_reason,omitempty"`
+}
+
+type ListTransfersResponse struct {
+	Transfers  []TransferStatusResponse `json:"transfers"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
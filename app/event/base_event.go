@@ -3,9 +3,22 @@ package event
 type TransferEventStatus string
 
 const (
-	CreatedTransferEventStatus TransferEventStatus = "created"
-	SentTransferEventStatus    TransferEventStatus = "sent"
-	FailedTransferEventStatus  TransferEventStatus = "failed"
+	CreatedTransferEventStatus   TransferEventStatus = "created"
+	SentTransferEventStatus      TransferEventStatus = "sent"
+	FailedTransferEventStatus    TransferEventStatus = "failed"
+	CancelledTransferEventStatus TransferEventStatus = "cancelled"
+	CompletedTransferEventStatus TransferEventStatus = "completed"
+	ReversedTransferEventStatus  TransferEventStatus = "reversed"
+)
+
+const (
+	TransferCreatedEventType   = "transfer_created"
+	TransferSentEventType      = "transfer_sent"
+	TransferFailedEventType    = "transfer_failed"
+	TransferCancelledEventType = "transfer_cancelled"
+	TransferSettledEventType   = "transfer_settled"
+	TransferCompletedEventType = "transfer_completed"
+	TransferReversedEventType  = "transfer_reversed"
 )
 
 type BaseEvent struct {
@@ -1,14 +1,18 @@
 package event
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
 
+// Transfer is the common transfer payload - amounts are serialized as decimal strings so no precision is lost on the wire
 type Transfer struct {
-	TransferId uuid.UUID `json:"transfer_id"`
-	FromAsset  string    `json:"from_asset"`
-	ToAsset    string    `json:"to_asset"`
-	Sender     string    `json:"sender"`
-	Recipient  string    `json:"recipient"`
-	Amount     float64   `json:"amount"`
-	Fee        float64   `json:"fee"`
-	Rate       float64   `json:"rate"`
+	TransferId uuid.UUID       `json:"transfer_id"`
+	FromAsset  string          `json:"from_asset"`
+	ToAsset    string          `json:"to_asset"`
+	Sender     string          `json:"sender"`
+	Recipient  string          `json:"recipient"`
+	Amount     decimal.Decimal `json:"amount"`
+	Fee        decimal.Decimal `json:"fee"`
+	Rate       decimal.Decimal `json:"rate"`
 }
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"encoding/json"
+	"sphere-homework/app/model"
+	"time"
+)
+
+type TransferCancelled struct {
+	Transfer
+	Status TransferEventStatus
+}
+
+func NewTransferCancelled(transfer model.Transfer) (*BaseEvent, error) {
+	cancelled := TransferCancelled{
+		Transfer: Transfer{
+			TransferId: transfer.TransferId,
+			FromAsset:  transfer.FromAsset,
+			ToAsset:    transfer.ToAsset,
+			Sender:     transfer.Sender,
+			Recipient:  transfer.Recipient,
+			Amount:     transfer.RequestedAmount,
+			Fee:        transfer.Fee,
+			Rate:       transfer.Rate,
+		},
+		Status: CancelledTransferEventStatus,
+	}
+
+	payload, err := json.Marshal(cancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseEvent{
+		Timestamp: time.Now().UnixMilli(),
+		EventType: TransferCancelledEventType,
+		Sender:    transfer.Sender,
+		Payload:   payload,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package event
+
+import (
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"sphere-homework/app/model"
+	"time"
+)
+
+type TransferCompleted struct {
+	Transfer
+	Status     TransferEventStatus
+	SentAmount decimal.Decimal
+}
+
+func NewTransferCompleted(transfer model.Transfer) (*BaseEvent, error) {
+	completed := TransferCompleted{
+		Transfer: Transfer{
+			TransferId: transfer.TransferId,
+			FromAsset:  transfer.FromAsset,
+			ToAsset:    transfer.ToAsset,
+			Sender:     transfer.Sender,
+			Recipient:  transfer.Recipient,
+			Amount:     transfer.RequestedAmount,
+			Fee:        transfer.Fee,
+			Rate:       transfer.Rate,
+		},
+		Status: CompletedTransferEventStatus,
+	}
+
+	if transfer.SentAmount != nil {
+		completed.SentAmount = *transfer.SentAmount
+	}
+
+	payload, err := json.Marshal(completed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseEvent{
+		Timestamp: time.Now().UnixMilli(),
+		EventType: TransferCompletedEventType,
+		Sender:    transfer.Sender,
+		Payload:   payload,
+	}, nil
+}
@@ -3,16 +3,18 @@ package event
 import (
 	"encoding/json"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"sphere-homework/app/dto"
 	"time"
 )
 
 type TransferCreated struct {
 	Transfer
-	Status TransferEventStatus
+	Status         TransferEventStatus
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
-func NewTransferCreated(request dto.TransferRequest, fee float64, rate float64, transferId uuid.UUID) (*BaseEvent, error) {
+func NewTransferCreated(request dto.TransferRequest, fee decimal.Decimal, rate decimal.Decimal, transferId uuid.UUID, idempotencyKey string) (*BaseEvent, error) {
 	created := TransferCreated{
 		Transfer: Transfer{
 			TransferId: transferId,
@@ -24,7 +26,8 @@ func NewTransferCreated(request dto.TransferRequest, fee float64, rate float64,
 			Fee:        fee,
 			Rate:       rate,
 		},
-		Status: CreatedTransferEventStatus,
+		Status:         CreatedTransferEventStatus,
+		IdempotencyKey: idempotencyKey,
 	}
 
 	payload, err := json.Marshal(created)
@@ -34,7 +37,7 @@ func NewTransferCreated(request dto.TransferRequest, fee float64, rate float64,
 
 	return &BaseEvent{
 		Timestamp: time.Now().UnixMilli(),
-		EventType: "transfer_created",
+		EventType: TransferCreatedEventType,
 		Sender:    request.Sender,
 		Payload:   payload,
 	}, nil
@@ -35,7 +35,7 @@ func NewTransferFailed(transfer model.Transfer) (*BaseEvent, error) {
 
 	return &BaseEvent{
 		Timestamp: time.Now().UnixMilli(),
-		EventType: "transfer_failed",
+		EventType: TransferFailedEventType,
 		Sender:    transfer.Sender,
 		Payload:   payload,
 	}, nil
@@ -0,0 +1,62 @@
+package event
+
+import (
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"sphere-homework/app/model"
+	"time"
+)
+
+// ReversalEntry is a compensating ledger entry booked by a reversal
+type ReversalEntry struct {
+	Account string          `json:"account"`
+	Asset   string          `json:"asset"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+type TransferReversed struct {
+	Transfer
+	Status  TransferEventStatus
+	Reason  string
+	Entries []ReversalEntry
+}
+
+func NewTransferReversed(reversal model.Reversal) (*BaseEvent, error) {
+	transfer := reversal.Transfer
+
+	reversed := TransferReversed{
+		Transfer: Transfer{
+			TransferId: transfer.TransferId,
+			FromAsset:  transfer.FromAsset,
+			ToAsset:    transfer.ToAsset,
+			Sender:     transfer.Sender,
+			Recipient:  transfer.Recipient,
+			Amount:     transfer.RequestedAmount,
+			Fee:        transfer.Fee,
+			Rate:       transfer.Rate,
+		},
+		Status:  ReversedTransferEventStatus,
+		Reason:  reversal.Reason,
+		Entries: make([]ReversalEntry, 0, len(reversal.Entries)),
+	}
+
+	for _, entry := range reversal.Entries {
+		reversed.Entries = append(reversed.Entries, ReversalEntry{
+			Account: entry.Account,
+			Asset:   entry.Asset,
+			Amount:  entry.Amount,
+		})
+	}
+
+	payload, err := json.Marshal(reversed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseEvent{
+		Timestamp: time.Now().UnixMilli(),
+		EventType: TransferReversedEventType,
+		Sender:    transfer.Sender,
+		Payload:   payload,
+	}, nil
+}
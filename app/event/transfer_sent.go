@@ -2,6 +2,7 @@ package event
 
 import (
 	"encoding/json"
+	"github.com/shopspring/decimal"
 	"sphere-homework/app/model"
 	"time"
 )
@@ -9,7 +10,7 @@ import (
 type TransferSent struct {
 	Transfer
 	Status     TransferEventStatus
-	SentAmount float64
+	SentAmount decimal.Decimal
 }
 
 func NewTransferSent(transfer model.Transfer) (*BaseEvent, error) {
@@ -35,7 +36,7 @@ func NewTransferSent(transfer model.Transfer) (*BaseEvent, error) {
 
 	return &BaseEvent{
 		Timestamp: time.Now().UnixMilli(),
-		EventType: "transfer_sent",
+		EventType: TransferSentEventType,
 		Sender:    transfer.Sender,
 		Payload:   payload,
 	}, nil
@@ -0,0 +1,35 @@
+package event
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"sphere-homework/app/model"
+	"time"
+)
+
+// TransferSettled is published by the payout rail once it confirmed (COMPLETED) or rejected (FAILED) a sent transfer
+type TransferSettled struct {
+	TransferId    uuid.UUID            `json:"transfer_id"`
+	Status        model.TransferStatus `json:"status"`
+	FailureReason string               `json:"failure_reason,omitempty"`
+}
+
+func NewTransferSettled(sender string, settlement model.Settlement) (*BaseEvent, error) {
+	settled := TransferSettled{
+		TransferId:    settlement.TransferId,
+		Status:        settlement.Status,
+		FailureReason: settlement.FailureReason,
+	}
+
+	payload, err := json.Marshal(settled)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BaseEvent{
+		Timestamp: time.Now().UnixMilli(),
+		EventType: TransferSettledEventType,
+		Sender:    sender,
+		Payload:   payload,
+	}, nil
+}
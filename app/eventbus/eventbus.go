@@ -0,0 +1,58 @@
+package eventbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPublishTimeout is returned when the delivery of a message could not be confirmed in time - the message may still be
+// delivered
+var ErrPublishTimeout = errors.New("timed out waiting for the delivery report")
+
+// ErrClosed is returned when reading from a subscriber that was closed
+var ErrClosed = errors.New("subscriber is closed")
+
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a message on the event bus - the topic, partition and offset are set by the bus once it is published
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Position returns where the message is on the bus, for logging
+func (m Message) Position() string {
+	return fmt.Sprintf("%s[%d]@%d", m.Topic, m.Partition, m.Offset)
+}
+
+// Publisher publishes messages to the topic they are addressed to, messages with the same key are delivered in order
+type Publisher interface {
+	// Publish returns once the bus acknowledged the message - it returns ErrPublishTimeout if the delivery could not be
+	// confirmed in time
+	Publish(msg Message) error
+	// PublishAsync hands the message to the bus without waiting for its delivery, callback is called with the delivery
+	// error, nil if delivered - it returns an error if the bus did not accept the message, in which case callback is not
+	// called
+	PublishAsync(msg Message, callback func(error)) error
+}
+
+// Subscriber reads the messages of the topics it is subscribed to on behalf of a consumer group - the messages of a
+// partition are read in order, starting after the last committed message of the group
+type Subscriber interface {
+	Subscribe(topic string) error
+	// Read returns the next message, or nil if none arrived within the timeout
+	Read(timeout time.Duration) (*Message, error)
+	// Commit records the message and the ones before it on its partition as handled by the consumer group
+	Commit(msg Message) error
+	// Rewind reads the message and the ones after it on its partition again
+	Rewind(msg Message) error
+	Close() error
+}
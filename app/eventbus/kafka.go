@@ -0,0 +1,195 @@
+package eventbus
+
+import (
+	"errors"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.uber.org/zap"
+	"sphere-homework/app/metrics"
+	"time"
+)
+
+// deliveryReportGrace is how long a publish waits for the delivery report on top of the producer's message timeout, after
+// which librdkafka reports a message it could not deliver as failed
+const deliveryReportGrace = time.Second
+
+// KafkaPublisher publishes messages with a kafka producer, and confirms their delivery with the producer's delivery reports
+type KafkaPublisher struct {
+	producer        *kafka.Producer
+	logger          *zap.Logger
+	deliveryTimeout time.Duration
+}
+
+// NewKafkaPublisher returns a publisher for the producer - messageTimeout is the producer's message.timeout.ms, the time
+// librdkafka takes at most to deliver or fail a message
+func NewKafkaPublisher(producer *kafka.Producer, logger *zap.Logger, messageTimeout time.Duration) *KafkaPublisher {
+	return &KafkaPublisher{
+		producer:        producer,
+		logger:          logger,
+		deliveryTimeout: messageTimeout + deliveryReportGrace,
+	}
+}
+
+// Init serves the delivery reports of messages published asynchronously and the errors of the producer, until the
+// producer is closed
+func (k *KafkaPublisher) Init() {
+	go func() {
+		for producerEvent := range k.producer.Events() {
+			switch report := producerEvent.(type) {
+			case *kafka.Message:
+				err := report.TopicPartition.Error
+				recordDelivery(err)
+
+				if callback, ok := report.Opaque.(func(error)); ok {
+					callback(err)
+				} else if err != nil {
+					k.logger.Error("Unable to deliver message", zap.Any("partition", report.TopicPartition), zap.Error(err))
+				}
+			case kafka.Error:
+				metrics.KafkaProducerErrors.Add(1)
+				k.logger.Error("Kafka producer error", zap.Error(report))
+			}
+		}
+	}()
+}
+
+func (k *KafkaPublisher) Publish(msg Message) error {
+	deliveries := make(chan kafka.Event, 1)
+
+	if err := k.producer.Produce(toKafkaMessage(msg), deliveries); err != nil {
+		recordDelivery(err)
+		return err
+	}
+
+	timer := time.NewTimer(k.deliveryTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		metrics.PublishedMessages.Add("timed_out", 1)
+		return ErrPublishTimeout
+	case delivery := <-deliveries:
+		err := fmt.Errorf("unexpected delivery event: %v", delivery)
+		if report, ok := delivery.(*kafka.Message); ok {
+			err = report.TopicPartition.Error
+		}
+
+		recordDelivery(err)
+		return err
+	}
+}
+
+func (k *KafkaPublisher) PublishAsync(msg Message, callback func(error)) error {
+	kafkaMsg := toKafkaMessage(msg)
+	kafkaMsg.Opaque = callback
+
+	if err := k.producer.Produce(kafkaMsg, nil); err != nil {
+		recordDelivery(err)
+		return err
+	}
+
+	return nil
+}
+
+// KafkaSubscriber reads messages with a kafka consumer, which is expected to run with auto-commit disabled
+type KafkaSubscriber struct {
+	consumer *kafka.Consumer
+}
+
+func NewKafkaSubscriber(consumer *kafka.Consumer) *KafkaSubscriber {
+	return &KafkaSubscriber{
+		consumer: consumer,
+	}
+}
+
+func (k *KafkaSubscriber) Subscribe(topic string) error {
+	return k.consumer.Subscribe(topic, nil)
+}
+
+func (k *KafkaSubscriber) Read(timeout time.Duration) (*Message, error) {
+	msg, err := k.consumer.ReadMessage(timeout)
+	if err != nil {
+		var kafkaErr kafka.Error
+		if errors.As(err, &kafkaErr) && kafkaErr.IsTimeout() {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if msg == nil {
+		return nil, nil
+	}
+
+	return fromKafkaMessage(msg), nil
+}
+
+func (k *KafkaSubscriber) Commit(msg Message) error {
+	topic := msg.Topic
+	_, err := k.consumer.CommitOffsets([]kafka.TopicPartition{{
+		Topic:     &topic,
+		Partition: msg.Partition,
+		Offset:    kafka.Offset(msg.Offset + 1),
+	}})
+
+	return err
+}
+
+func (k *KafkaSubscriber) Rewind(msg Message) error {
+	topic := msg.Topic
+	return k.consumer.Seek(kafka.TopicPartition{
+		Topic:     &topic,
+		Partition: msg.Partition,
+		Offset:    kafka.Offset(msg.Offset),
+	}, 0)
+}
+
+func (k *KafkaSubscriber) Close() error {
+	return k.consumer.Close()
+}
+
+func toKafkaMessage(msg Message) *kafka.Message {
+	topic := msg.Topic
+	kafkaMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:   msg.Key,
+		Value: msg.Value,
+	}
+
+	for _, header := range msg.Headers {
+		kafkaMsg.Headers = append(kafkaMsg.Headers, kafka.Header{Key: header.Key, Value: header.Value})
+	}
+
+	return kafkaMsg
+}
+
+func fromKafkaMessage(kafkaMsg *kafka.Message) *Message {
+	msg := &Message{
+		Partition: kafkaMsg.TopicPartition.Partition,
+		Offset:    int64(kafkaMsg.TopicPartition.Offset),
+		Key:       kafkaMsg.Key,
+		Value:     kafkaMsg.Value,
+	}
+
+	if kafkaMsg.TopicPartition.Topic != nil {
+		msg.Topic = *kafkaMsg.TopicPartition.Topic
+	}
+
+	for _, header := range kafkaMsg.Headers {
+		msg.Headers = append(msg.Headers, Header{Key: header.Key, Value: header.Value})
+	}
+
+	return msg
+}
+
+func recordDelivery(err error) {
+	if err != nil {
+		metrics.PublishedMessages.Add("failed", 1)
+		return
+	}
+
+	metrics.PublishedMessages.Add("delivered", 1)
+}
@@ -0,0 +1,152 @@
+package eventbus
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryBus is an in-process event bus for tests - every topic has a single partition that keeps all messages, and
+// consumer groups remember their committed offsets like kafka does
+type MemoryBus struct {
+	mu        sync.Mutex
+	topics    map[string][]Message
+	committed map[string]map[string]int64 // next offset to read by consumer group and topic
+	published chan struct{}               // closed and replaced whenever a message is published
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		topics:    map[string][]Message{},
+		committed: map[string]map[string]int64{},
+		published: make(chan struct{}),
+	}
+}
+
+func (b *MemoryBus) Publish(msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg.Partition = 0
+	msg.Offset = int64(len(b.topics[msg.Topic]))
+	b.topics[msg.Topic] = append(b.topics[msg.Topic], msg)
+
+	close(b.published)
+	b.published = make(chan struct{})
+
+	return nil
+}
+
+// PublishAsync publishes the message right away, and calls callback before it returns
+func (b *MemoryBus) PublishAsync(msg Message, callback func(error)) error {
+	err := b.Publish(msg)
+	if callback != nil {
+		callback(err)
+	}
+
+	return nil
+}
+
+// Messages returns the messages published to the topic so far
+func (b *MemoryBus) Messages(topic string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.topics[topic]...)
+}
+
+// Subscriber returns a subscriber reading on behalf of the consumer group
+func (b *MemoryBus) Subscriber(group string) *MemorySubscriber {
+	return &MemorySubscriber{
+		bus:       b,
+		group:     group,
+		positions: map[string]int64{},
+	}
+}
+
+// MemorySubscriber reads the messages of a MemoryBus on behalf of a consumer group
+type MemorySubscriber struct {
+	bus       *MemoryBus
+	group     string
+	topics    []string
+	positions map[string]int64 // next offset to read by topic
+	closed    bool
+}
+
+func (s *MemorySubscriber) Subscribe(topic string) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.topics = append(s.topics, topic)
+	s.positions[topic] = s.bus.committed[s.group][topic]
+
+	return nil
+}
+
+func (s *MemorySubscriber) Read(timeout time.Duration) (*Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.bus.mu.Lock()
+		if s.closed {
+			s.bus.mu.Unlock()
+			return nil, ErrClosed
+		}
+
+		for _, topic := range s.topics {
+			if position := s.positions[topic]; position < int64(len(s.bus.topics[topic])) {
+				msg := s.bus.topics[topic][position]
+				s.positions[topic] = position + 1
+				s.bus.mu.Unlock()
+
+				return &msg, nil
+			}
+		}
+
+		published := s.bus.published
+		s.bus.mu.Unlock()
+
+		select {
+		case <-published:
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+func (s *MemorySubscriber) Commit(msg Message) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if s.bus.committed[s.group] == nil {
+		s.bus.committed[s.group] = map[string]int64{}
+	}
+
+	if msg.Offset+1 > s.bus.committed[s.group][msg.Topic] {
+		s.bus.committed[s.group][msg.Topic] = msg.Offset + 1
+	}
+
+	return nil
+}
+
+func (s *MemorySubscriber) Rewind(msg Message) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.positions[msg.Topic] = msg.Offset
+
+	return nil
+}
+
+// Close closes the subscriber, and wakes up a read waiting for a message
+func (s *MemorySubscriber) Close() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.closed = true
+
+	close(s.bus.published)
+	s.bus.published = make(chan struct{})
+
+	return nil
+}
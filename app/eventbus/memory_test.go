@@ -0,0 +1,106 @@
+package eventbus
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusDeliversInOrder(t *testing.T) {
+	bus := NewMemoryBus()
+	subscriber := bus.Subscriber("group")
+	assert.NoError(t, subscriber.Subscribe("topic"))
+
+	assert.NoError(t, bus.Publish(Message{Topic: "topic", Value: []byte("a")}))
+	assert.NoError(t, bus.Publish(Message{Topic: "other", Value: []byte("x")}))
+	assert.NoError(t, bus.Publish(Message{Topic: "topic", Value: []byte("b")}))
+
+	first, err := subscriber.Read(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(first.Value))
+	assert.Equal(t, int64(0), first.Offset)
+
+	second, err := subscriber.Read(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(second.Value))
+	assert.Equal(t, int64(1), second.Offset)
+
+	none, err := subscriber.Read(10 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestMemoryBusReadWaitsForPublish(t *testing.T) {
+	bus := NewMemoryBus()
+	subscriber := bus.Subscriber("group")
+	assert.NoError(t, subscriber.Subscribe("topic"))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = bus.Publish(Message{Topic: "topic", Value: []byte("a")})
+	}()
+
+	msg, err := subscriber.Read(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(msg.Value))
+}
+
+func TestMemoryBusResumesAfterCommittedOffset(t *testing.T) {
+	bus := NewMemoryBus()
+	for _, value := range []string{"a", "b", "c"} {
+		assert.NoError(t, bus.Publish(Message{Topic: "topic", Value: []byte(value)}))
+	}
+
+	subscriber := bus.Subscriber("group")
+	assert.NoError(t, subscriber.Subscribe("topic"))
+
+	msg, _ := subscriber.Read(time.Second)
+	assert.NoError(t, subscriber.Commit(*msg))
+	_, _ = subscriber.Read(time.Second)
+	assert.NoError(t, subscriber.Close())
+
+	// the second message was read but not committed, so it is redelivered to the group
+	restarted := bus.Subscriber("group")
+	assert.NoError(t, restarted.Subscribe("topic"))
+
+	msg, _ = restarted.Read(time.Second)
+	assert.Equal(t, "b", string(msg.Value))
+
+	// other groups read from the start
+	other := bus.Subscriber("other")
+	assert.NoError(t, other.Subscribe("topic"))
+
+	msg, _ = other.Read(time.Second)
+	assert.Equal(t, "a", string(msg.Value))
+}
+
+func TestMemorySubscriberRewind(t *testing.T) {
+	bus := NewMemoryBus()
+	for _, value := range []string{"a", "b", "c"} {
+		assert.NoError(t, bus.Publish(Message{Topic: "topic", Value: []byte(value)}))
+	}
+
+	subscriber := bus.Subscriber("group")
+	assert.NoError(t, subscriber.Subscribe("topic"))
+
+	_, _ = subscriber.Read(time.Second)
+	second, _ := subscriber.Read(time.Second)
+	assert.NoError(t, subscriber.Rewind(*second))
+
+	msg, _ := subscriber.Read(time.Second)
+	assert.Equal(t, "b", string(msg.Value))
+}
+
+func TestMemorySubscriberClose(t *testing.T) {
+	bus := NewMemoryBus()
+	subscriber := bus.Subscriber("group")
+	assert.NoError(t, subscriber.Subscribe("topic"))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = subscriber.Close()
+	}()
+
+	_, err := subscriber.Read(time.Second)
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/services"
+	"strconv"
+	"time"
+)
+
+const defaultTransferPageSize = 50
+const maxTransferPageSize = 200
+
+func ListAccountTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := model.TransferFilter{
+		Account: mux.Vars(r)["account"],
+		Limit:   defaultTransferPageSize,
+	}
+
+	if value := query.Get("status"); value != "" {
+		status := model.TransferStatus(value)
+		if !model.IsValidTransferStatus(status) {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid status: "+value)
+			return
+		}
+		filter.Status = &status
+	}
+
+	if value := query.Get("asset"); value != "" {
+		filter.Asset = &value
+	}
+
+	if value := query.Get("transfer_type"); value != "" {
+		transferType := model.TransferType(value)
+		if !model.IsValidTransferType(transferType) {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid transfer type: "+value)
+			return
+		}
+		filter.TransferType = &transferType
+	}
+
+	if value := query.Get("created_from"); value != "" {
+		createdFrom, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid created_from: "+value)
+			return
+		}
+		filter.CreatedFrom = &createdFrom
+	}
+
+	if value := query.Get("created_to"); value != "" {
+		createdTo, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid created_to: "+value)
+			return
+		}
+		filter.CreatedTo = &createdTo
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxTransferPageSize {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid limit: "+value)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeTransferCursor(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid cursor")
+			return
+		}
+		filter.After = cursor
+	}
+
+	pageSize := filter.Limit
+
+	// fetch one more than the page size to know if there is a next page
+	filter.Limit = pageSize + 1
+
+	transfers, err := middleware.GetTransferRepository(r).ListAccountTransfers(filter)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to list transfers", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to list transfers")
+		return
+	}
+
+	response := dto.ListTransfersResponse{
+		Transfers: []dto.TransferStatusResponse{},
+	}
+
+	if len(transfers) > pageSize {
+		transfers = transfers[:pageSize]
+
+		response.NextCursor, err = encodeTransferCursor(transfers[pageSize-1])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to create cursor")
+			return
+		}
+	}
+
+	for _, transfer := range transfers {
+		response.Transfers = append(response.Transfers, toTransferStatusResponse(transfer))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func GetAccountBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+
+	balances, err := middleware.GetLedgerRepository(r).GetAccountBalances(account)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch balances", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch balances")
+		return
+	}
+
+	if len(balances) == 0 {
+		writeError(w, http.StatusNotFound, dto.AccountNotFoundErrorCode, "Account not found: "+account)
+		return
+	}
+
+	response := dto.AccountBalancesResponse{
+		Account:  account,
+		Balances: []dto.AccountBalanceResponse{},
+	}
+
+	for _, balance := range balances {
+		response.Balances = append(response.Balances, toAccountBalanceResponse(balance))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func GetAccountAssetBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	asset := mux.Vars(r)["asset"]
+
+	balance, err := middleware.GetLedgerRepository(r).GetAccountBalance(account, asset)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch balance", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch balance")
+		return
+	}
+
+	if balance == nil {
+		writeError(w, http.StatusNotFound, dto.AccountNotFoundErrorCode, "Account "+account+" has no "+asset+" balance")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toAccountBalanceResponse(*balance))
+}
+
+func toAccountBalanceResponse(balance model.AccountBalance) dto.AccountBalanceResponse {
+	return dto.AccountBalanceResponse{
+		Asset:           balance.Asset,
+		Balance:         balance.Balance,
+		Held:            balance.Held,
+		PendingOutgoing: balance.PendingOutgoing,
+		Available:       balance.Available,
+	}
+}
+
+func GetAccountStatementHandler(w http.ResponseWriter, r *http.Request) {
+	account := mux.Vars(r)["account"]
+	query := r.URL.Query()
+
+	from, err := services.ParseStatementTime(query.Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid from: "+query.Get("from"))
+		return
+	}
+
+	to, err := services.ParseStatementTime(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid to: "+query.Get("to"))
+		return
+	}
+
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "from must be before to")
+		return
+	}
+
+	var asset *string
+	if value := query.Get("asset"); value != "" {
+		asset = &value
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid format: "+format)
+		return
+	}
+
+	statement, err := middleware.GetLedgerRepository(r).GetStatement(account, from, to, asset)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch statement", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch statement")
+		return
+	}
+
+	if len(statement.Assets) == 0 {
+		writeError(w, http.StatusNotFound, dto.AccountNotFoundErrorCode, "Account not found: "+account)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("statement-%s-%s-%s.csv", account, from.Format(time.DateOnly), to.Format(time.DateOnly))))
+		w.WriteHeader(http.StatusOK)
+
+		if err := services.WriteStatementCSV(w, *statement); err != nil {
+			middleware.GetLogger(r).Error("Unable to write statement", zap.Error(err))
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, services.ToStatementResponse(*statement))
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"regexp"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"strings"
+)
+
+var assetCodePattern = regexp.MustCompile(`^[A-Z0-9]{2,12}$`)
+
+func CreateAssetHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to read request")
+		return
+	}
+
+	request := dto.CreateAssetRequest{}
+
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to parse request")
+		return
+	}
+
+	code := strings.ToUpper(request.Code)
+	if !assetCodePattern.MatchString(code) {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid asset code: "+request.Code)
+		return
+	}
+
+	if request.MinorUnits < 0 || request.MinorUnits > model.StorageScale {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid minor units")
+		return
+	}
+
+	roundingMode := model.HalfEvenRoundingMode
+	if request.RoundingMode != "" {
+		roundingMode = model.RoundingMode(request.RoundingMode)
+	}
+
+	if !model.IsValidRoundingMode(roundingMode) {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid rounding mode: "+request.RoundingMode)
+		return
+	}
+
+	repository := middleware.GetAssetRepository(r)
+
+	existing, err := repository.GetAsset(code)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch asset", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch asset")
+		return
+	}
+
+	if existing != nil {
+		writeError(w, http.StatusConflict, dto.AssetExistsErrorCode, "Asset already exists: "+code)
+		return
+	}
+
+	asset, err := repository.InsertAsset(model.Asset{
+		Code:         code,
+		MinorUnits:   request.MinorUnits,
+		RoundingMode: roundingMode,
+	})
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to create asset", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to create asset")
+		return
+	}
+
+	refreshAssetRegistry(r)
+
+	writeJSON(w, http.StatusCreated, toAssetResponse(*asset))
+}
+
+func DisableAssetHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(mux.Vars(r)["code"])
+
+	repository := middleware.GetAssetRepository(r)
+
+	asset, err := repository.DisableAsset(code)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to disable asset", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to disable asset")
+		return
+	}
+
+	if asset == nil {
+		writeError(w, http.StatusNotFound, dto.AssetNotFoundErrorCode, "Asset not found: "+code)
+		return
+	}
+
+	refreshAssetRegistry(r)
+
+	writeJSON(w, http.StatusOK, toAssetResponse(*asset))
+}
+
+func ListAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	repository := middleware.GetAssetRepository(r)
+
+	assets, err := repository.GetAssets()
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch assets", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch assets")
+		return
+	}
+
+	response := dto.ListAssetsResponse{
+		Assets: []dto.AssetResponse{},
+	}
+
+	for _, asset := range assets {
+		response.Assets = append(response.Assets, toAssetResponse(asset))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func refreshAssetRegistry(r *http.Request) {
+	if err := middleware.GetAssetRegistry(r).Refresh(); err != nil {
+		middleware.GetLogger(r).Error("Unable to refresh asset registry", zap.Error(err))
+	}
+}
+
+func toAssetResponse(asset model.Asset) dto.AssetResponse {
+	return dto.AssetResponse{
+		Code:         asset.Code,
+		MinorUnits:   asset.MinorUnits,
+		RoundingMode: string(asset.RoundingMode),
+		Enabled:      asset.Enabled,
+		CreatedAt:    asset.CreatedAt,
+		UpdatedAt:    asset.UpdatedAt,
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"sphere-homework/app/model"
+	"time"
+)
+
+type transferCursor struct {
+	CreatedAt  time.Time `json:"c"`
+	TransferId uuid.UUID `json:"t"`
+}
+
+// encodeTransferCursor returns an opaque cursor pointing after the transfer
+func encodeTransferCursor(transfer model.Transfer) (string, error) {
+	b, err := json.Marshal(transferCursor{
+		CreatedAt:  transfer.CreatedAt,
+		TransferId: transfer.TransferId,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeTransferCursor(cursor string) (*model.TransferCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := transferCursor{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+
+	return &model.TransferCursor{
+		CreatedAt:  decoded.CreatedAt,
+		TransferId: decoded.TransferId,
+	}, nil
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/model"
+	"testing"
+	"time"
+)
+
+func TestTransferCursorRoundTrip(t *testing.T) {
+	transfer := model.Transfer{
+		TransferId: uuid.New(),
+		CreatedAt:  time.Date(2024, 11, 3, 10, 15, 30, 123456000, time.UTC),
+	}
+
+	cursor, err := encodeTransferCursor(transfer)
+	assert.NoError(t, err)
+
+	decoded, err := decodeTransferCursor(cursor)
+	assert.NoError(t, err)
+
+	assert.Equal(t, transfer.TransferId, decoded.TransferId)
+	assert.True(t, transfer.CreatedAt.Equal(decoded.CreatedAt))
+}
+
+func TestTransferCursorRejectsGarbage(t *testing.T) {
+	_, err := decodeTransferCursor("not a cursor")
+
+	assert.Error(t, err)
+}
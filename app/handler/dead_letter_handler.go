@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"strconv"
+)
+
+const defaultDeadLetterPageSize = 50
+const maxDeadLetterPageSize = 200
+
+// ListDeadLettersHandler lists the dead letters, most recent first - the cursor is the id of the last dead letter of the
+// previous page
+func ListDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := model.DeadLetterFilter{
+		Limit: defaultDeadLetterPageSize,
+	}
+
+	if value := query.Get("consumer"); value != "" {
+		filter.Consumer = &value
+	}
+
+	if value := query.Get("replayed"); value != "" {
+		replayed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid replayed: "+value)
+			return
+		}
+		filter.Replayed = &replayed
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxDeadLetterPageSize {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid limit: "+value)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		beforeId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid cursor")
+			return
+		}
+		filter.BeforeId = &beforeId
+	}
+
+	pageSize := filter.Limit
+
+	// fetch one more than the page size to know if there is a next page
+	filter.Limit = pageSize + 1
+
+	deadLetters, err := middleware.GetDeadLetterRepository(r).ListDeadLetters(filter)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to list dead letters", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to list dead letters")
+		return
+	}
+
+	response := dto.ListDeadLettersResponse{
+		DeadLetters: []dto.DeadLetterResponse{},
+	}
+
+	if len(deadLetters) > pageSize {
+		deadLetters = deadLetters[:pageSize]
+		response.NextCursor = strconv.FormatInt(deadLetters[pageSize-1].Id, 10)
+	}
+
+	for _, deadLetter := range deadLetters {
+		response.DeadLetters = append(response.DeadLetters, toDeadLetterResponse(deadLetter))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ReplayDeadLetterHandler publishes a dead letter back onto the transfer topic, where every consumer receives it again
+func ReplayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid dead letter id")
+		return
+	}
+
+	deadLetter, err := middleware.GetDeadLetterService(r).Replay(id)
+	if errors.Is(err, repository.ErrDeadLetterReplayed) {
+		writeError(w, http.StatusConflict, dto.DeadLetterReplayedErrorCode, "Dead letter was already replayed: "+strconv.FormatInt(id, 10))
+		return
+	}
+
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to replay dead letter", zap.Int64("id", id), zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to replay dead letter")
+		return
+	}
+
+	if deadLetter == nil {
+		writeError(w, http.StatusNotFound, dto.DeadLetterNotFoundErrorCode, "Dead letter not found: "+strconv.FormatInt(id, 10))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toDeadLetterResponse(*deadLetter))
+}
+
+func toDeadLetterResponse(deadLetter model.DeadLetter) dto.DeadLetterResponse {
+	response := dto.DeadLetterResponse{
+		Id:         deadLetter.Id,
+		CreatedAt:  deadLetter.CreatedAt,
+		Consumer:   deadLetter.Consumer,
+		Error:      deadLetter.Error,
+		Topic:      deadLetter.Topic,
+		Partition:  deadLetter.Partition,
+		Offset:     deadLetter.Offset,
+		Key:        string(deadLetter.Key),
+		Payload:    string(deadLetter.Payload),
+		Headers:    make([]dto.DeadLetterHeaderResponse, 0, len(deadLetter.Headers)),
+		ReplayedAt: deadLetter.ReplayedAt,
+	}
+
+	for _, header := range deadLetter.Headers {
+		response.Headers = append(response.Headers, dto.DeadLetterHeaderResponse{
+			Key:   header.Key,
+			Value: string(header.Value),
+		})
+	}
+
+	return response
+}
@@ -2,11 +2,12 @@ package handler
 
 import (
 	"encoding/json"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 	"io"
 	"net/http"
 	"sphere-homework/app/dto"
 	"sphere-homework/app/middleware"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -16,14 +17,14 @@ func ExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Unable to parse request", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to read request")
 		return
 	}
 
 	request := dto.UpdateExchangeRateRequest{}
 
 	if err := json.Unmarshal(body, &request); err != nil {
-		http.Error(w, "Unable to parse request", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to parse request")
 		return
 	}
 
@@ -31,36 +32,30 @@ func ExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
 
 	split := strings.Split(request.Pair, "/")
 	if len(split) != 2 {
-		http.Error(w, "Invalid pair: "+request.Pair, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid pair: "+request.Pair)
 		return
 	}
 
-	rate, err := strconv.ParseFloat(request.Rate, 64)
+	rate, err := decimal.NewFromString(request.Rate)
 	if err != nil {
-		http.Error(w, "Invalid rate: "+request.Rate, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid rate: "+request.Rate)
 		return
 	}
 
 	timestamp, err := time.Parse(time.RFC3339Nano, request.Timestamp)
 	if err != nil {
-		http.Error(w, "Invalid timestamp: "+request.Timestamp, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid timestamp: "+request.Timestamp)
 		return
 	}
 
 	err = repository.UpsertRate(split[0], split[1], rate, timestamp)
 	if err != nil {
-		http.Error(w, "Unable to update rate: "+err.Error(), http.StatusBadRequest)
+		middleware.GetLogger(r).Error("Unable to update rate", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to update rate")
 		return
 	}
 
-	response := dto.UpdateExchangeRateResponse{
+	writeJSON(w, http.StatusOK, dto.UpdateExchangeRateResponse{
 		Status: "ok",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Unable to write response", http.StatusInternalServerError)
-	}
+	})
 }
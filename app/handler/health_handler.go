@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+)
+
+// HealthHandler reports whether every background service is running
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	response := dto.HealthResponse{
+		Status:   dto.HealthyStatus,
+		Services: map[string]string{},
+	}
+
+	for _, service := range middleware.GetServices(r) {
+		if err := service.Health(); err != nil {
+			response.Status = dto.UnhealthyStatus
+			response.Services[service.Name()] = err.Error()
+			continue
+		}
+
+		response.Services[service.Name()] = dto.HealthyStatus
+	}
+
+	if response.Status != dto.HealthyStatus {
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+const maxIdempotencyKeyLength = 255
+
+// hashTransferRequest fingerprints the request so a key reused with a different request can be detected
+func hashTransferRequest(request dto.TransferRequest) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reserveIdempotencyKey stores the key along with the response to replay on retries, as accepted until the transfer event
+// is confirmed delivered - it returns the stored key instead if another request reserved it first
+func reserveIdempotencyKey(r *http.Request, key string, requestHash string, sender string, response dto.TransferResponse) (*model.IdempotencyKey, error) {
+	repository := middleware.GetIdempotencyKeyRepository(r)
+	config := middleware.GetConfig(r)
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	reserved, err := repository.ReserveIdempotencyKey(model.IdempotencyKey{
+		Key:         key,
+		Sender:      sender,
+		RequestHash: requestHash,
+		TransferId:  response.TransferId,
+		Response:    b,
+		StatusCode:  http.StatusAccepted,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Duration(config.IdempotencyKeyTtlSec) * time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reserved {
+		return nil, nil
+	}
+
+	existing, err := repository.GetIdempotencyKey(sender, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return nil, fmt.Errorf("idempotency key %s is taken but could not be found", key)
+	}
+
+	return existing, nil
+}
+
+// confirmIdempotencyKey replays the response of the sender's key as created, once the transfer event was delivered
+func confirmIdempotencyKey(r *http.Request, sender string, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := middleware.GetIdempotencyKeyRepository(r).SetIdempotencyKeyStatusCode(sender, key, http.StatusCreated); err != nil {
+		middleware.GetLogger(r).Error("Unable to confirm idempotency key", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// releaseIdempotencyKey frees the sender's key of a request that failed after reserving it, so the client can retry
+func releaseIdempotencyKey(r *http.Request, sender string, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := middleware.GetIdempotencyKeyRepository(r).DeleteIdempotencyKey(sender, key); err != nil {
+		middleware.GetLogger(r).Error("Unable to release idempotency key", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// replayIdempotentResponse writes the response of the request that first used the key with its status code, or a
+// conflict if the key was used for a different request
+func replayIdempotentResponse(w http.ResponseWriter, key *model.IdempotencyKey, requestHash string) {
+	if key.RequestHash != requestHash {
+		writeError(w, http.StatusConflict, dto.IdempotencyKeyConflictErrorCode, "Idempotency key was already used for a different request")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(key.StatusCode)
+
+	if _, err := w.Write(key.Response); err != nil {
+		http.Error(w, "Unable to write response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/model"
+	"testing"
+)
+
+func TestHashTransferRequestIgnoresAmountFormatting(t *testing.T) {
+	request := dto.TransferRequest{
+		FromAsset: "USD",
+		ToAsset:   "GBP",
+		Amount:    decimal.RequireFromString("10.50"),
+		Sender:    "jim",
+		Recipient: "jacob",
+	}
+
+	retry := request
+	retry.Amount = decimal.RequireFromString("10.5")
+
+	hash, err := hashTransferRequest(request)
+	assert.NoError(t, err)
+
+	retryHash, err := hashTransferRequest(retry)
+	assert.NoError(t, err)
+
+	assert.Equal(t, hash, retryHash)
+}
+
+func TestHashTransferRequestDetectsDifferentRequest(t *testing.T) {
+	request := dto.TransferRequest{
+		FromAsset: "USD",
+		ToAsset:   "GBP",
+		Amount:    decimal.NewFromInt(10),
+		Sender:    "jim",
+		Recipient: "jacob",
+	}
+
+	other := request
+	other.Recipient = "system"
+
+	hash, err := hashTransferRequest(request)
+	assert.NoError(t, err)
+
+	otherHash, err := hashTransferRequest(other)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, hash, otherHash)
+}
+
+func TestReplayIdempotentResponseKeepsStatusCode(t *testing.T) {
+	key := &model.IdempotencyKey{
+		RequestHash: "hash",
+		Response:    []byte(`{"transfer_id":"8b0e4a4e-3d5c-4b8e-9f6a-2a7c1f0d9e11"}`),
+		StatusCode:  http.StatusAccepted,
+	}
+
+	recorder := httptest.NewRecorder()
+	replayIdempotentResponse(recorder, key, "hash")
+
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, "true", recorder.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, string(key.Response), recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	replayIdempotentResponse(recorder, key, "other")
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sphere-homework/app/dto"
+)
+
+func writeJSON(w http.ResponseWriter, status int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Unable to write response", http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code dto.ErrorCode, message string) {
+	writeJSON(w, status, dto.ErrorResponse{
+		Code:    code,
+		Message: message,
+	})
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"strings"
+)
+
+// ReverseTransferHandler fails a sent or completed transfer and refunds the sender the principal and the fee
+func ReverseTransferHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	transferId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid transfer id")
+		return
+	}
+
+	request := dto.ReverseTransferRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to parse request")
+		return
+	}
+
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "reason is required")
+		return
+	}
+
+	reversal, err := middleware.GetSettlementService(r).Reverse(transferId, reason)
+	if errors.Is(err, repository.ErrReversalNotCovered) {
+		writeError(w, http.StatusUnprocessableEntity, dto.ReversalNotCoveredErrorCode, err.Error())
+		return
+	}
+
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to reverse transfer", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to reverse transfer")
+		return
+	}
+
+	if reversal == nil {
+		transfer, err := middleware.GetTransferRepository(r).GetTransfer(transferId)
+		if err != nil {
+			middleware.GetLogger(r).Error("Unable to fetch transfer", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch transfer")
+			return
+		}
+
+		if transfer == nil {
+			writeError(w, http.StatusNotFound, dto.TransferNotFoundErrorCode, "Transfer not found: "+transferId.String())
+			return
+		}
+
+		writeError(w, http.StatusConflict, dto.TransferNotReversibleErrorCode, "Transfer is "+string(transfer.TransferStatus))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toReversalResponse(*reversal))
+}
+
+func toReversalResponse(reversal model.Reversal) dto.ReversalResponse {
+	response := dto.ReversalResponse{
+		Transfer: toTransferStatusResponse(reversal.Transfer),
+		Reason:   reversal.Reason,
+		Entries:  make([]dto.ReversalEntryResponse, 0, len(reversal.Entries)),
+	}
+
+	for _, entry := range reversal.Entries {
+		response.Entries = append(response.Entries, dto.ReversalEntryResponse{
+			Account: entry.Account,
+			Asset:   entry.Asset,
+			Amount:  entry.Amount,
+		})
+	}
+
+	return response
+}
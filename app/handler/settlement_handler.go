@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"net/http"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+)
+
+// defaultRailFailureReason is recorded when the rail rejects a transfer without saying why
+const defaultRailFailureReason = "rejected by rail"
+
+// SettlementCallbackHandler receives the payout rail's confirmation of a sent transfer
+func SettlementCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	request := dto.SettlementCallbackRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to parse request")
+		return
+	}
+
+	settlement, err := toSettlement(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, err.Error())
+		return
+	}
+
+	settled, err := middleware.GetSettlementService(r).Settle(settlement)
+	if errors.Is(err, repository.ErrReversalNotCovered) {
+		writeError(w, http.StatusUnprocessableEntity, dto.ReversalNotCoveredErrorCode, err.Error())
+		return
+	}
+
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to settle transfer", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to settle transfer")
+		return
+	}
+
+	if settled != nil {
+		writeJSON(w, http.StatusOK, toTransferStatusResponse(*settled))
+		return
+	}
+
+	transfer, err := middleware.GetTransferRepository(r).GetTransfer(settlement.TransferId)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch transfer", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch transfer")
+		return
+	}
+
+	if transfer == nil {
+		writeError(w, http.StatusNotFound, dto.TransferNotFoundErrorCode, "Transfer not found: "+settlement.TransferId.String())
+		return
+	}
+
+	// the rail retried a callback that was already applied
+	if transfer.TransferStatus == settlement.Status {
+		writeJSON(w, http.StatusOK, toTransferStatusResponse(*transfer))
+		return
+	}
+
+	writeError(w, http.StatusConflict, dto.TransferNotSettleableErrorCode, "Transfer is "+string(transfer.TransferStatus))
+}
+
+func toSettlement(request dto.SettlementCallbackRequest) (model.Settlement, error) {
+	if request.TransferId == uuid.Nil {
+		return model.Settlement{}, fmt.Errorf("transfer_id is required")
+	}
+
+	status := model.TransferStatus(request.Status)
+	if !model.IsValidSettlementStatus(status) {
+		return model.Settlement{}, fmt.Errorf("status must be %s or %s", model.CompletedTransferStatus, model.FailedTransferStatus)
+	}
+
+	settlement := model.Settlement{
+		TransferId: request.TransferId,
+		Status:     status,
+	}
+
+	if status == model.FailedTransferStatus {
+		settlement.FailureReason = request.FailureReason
+		if settlement.FailureReason == "" {
+			settlement.FailureReason = defaultRailFailureReason
+		}
+	}
+
+	return settlement, nil
+}
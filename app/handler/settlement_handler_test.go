@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/model"
+	"testing"
+)
+
+func TestToSettlement(t *testing.T) {
+	transferId := uuid.New()
+
+	settlement, err := toSettlement(dto.SettlementCallbackRequest{TransferId: transferId, Status: "COMPLETED", FailureReason: "ignored"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Settlement{TransferId: transferId, Status: model.CompletedTransferStatus}, settlement)
+
+	settlement, err = toSettlement(dto.SettlementCallbackRequest{TransferId: transferId, Status: "FAILED"})
+	assert.NoError(t, err)
+	assert.Equal(t, model.FailedTransferStatus, settlement.Status)
+	assert.Equal(t, defaultRailFailureReason, settlement.FailureReason)
+
+	settlement, err = toSettlement(dto.SettlementCallbackRequest{TransferId: transferId, Status: "FAILED", FailureReason: "account closed"})
+	assert.NoError(t, err)
+	assert.Equal(t, "account closed", settlement.FailureReason)
+}
+
+func TestToSettlementRejectsInvalidRequests(t *testing.T) {
+	_, err := toSettlement(dto.SettlementCallbackRequest{Status: "COMPLETED"})
+	assert.Error(t, err)
+
+	_, err = toSettlement(dto.SettlementCallbackRequest{TransferId: uuid.New(), Status: "SENT"})
+	assert.Error(t, err)
+}
@@ -2,12 +2,20 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 	"io"
 	"net/http"
 	"sphere-homework/app/dto"
 	event2 "sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
 	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"sphere-homework/app/services"
+	"time"
 )
 
 func TransferHandler(w http.ResponseWriter, r *http.Request) {
@@ -15,26 +23,66 @@ func TransferHandler(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		panic(err)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to read request")
+		return
 	}
 
 	request := dto.TransferRequest{}
 
-	// TBD - do quick balance, user, and asset validation
-
 	if err := json.Unmarshal(body, &request); err != nil {
-		http.Error(w, "Unable to parse request", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Unable to parse request")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	// a retry with an already used idempotency key gets the original response back, without being validated again
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	var requestHash string
+
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Idempotency key is too long")
+			return
+		}
+
+		requestHash, err = hashTransferRequest(request)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to hash request")
+			return
+		}
+
+		existing, err := middleware.GetIdempotencyKeyRepository(r).GetIdempotencyKey(request.Sender, idempotencyKey)
+		if err != nil {
+			middleware.GetLogger(r).Error("Unable to fetch idempotency key", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch idempotency key")
+			return
+		}
+
+		if existing != nil {
+			replayIdempotentResponse(w, existing, requestHash)
+			return
+		}
+	}
+
+	validator := middleware.GetTransferValidator(r)
+
+	err = validator.Validate(request)
+	if err != nil {
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			writeError(w, validationErr.Status, validationErr.Code, validationErr.Message)
+			return
+		}
+
+		middleware.GetLogger(r).Error("Unable to validate transfer request", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to validate transfer request")
+		return
+	}
 
 	rateRepository := middleware.GetRateRepository(r)
 
 	rate, err := rateRepository.GetRate(request.FromAsset, request.ToAsset)
 	if err != nil {
-		http.Error(w, "Unable to fetch rate: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.RateUnavailableErrorCode, "Unable to fetch rate: "+err.Error())
 		return
 	}
 
@@ -42,29 +90,193 @@ func TransferHandler(w http.ResponseWriter, r *http.Request) {
 
 	fee, err := feeRepository.GetFee(request.ToAsset)
 	if err != nil {
-		http.Error(w, "Unable to fetch fee: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, dto.FeeUnavailableErrorCode, "Unable to fetch fee: "+err.Error())
 		return
 	}
 
 	transferId := uuid.New()
-	event, err := event2.NewTransferCreated(request, fee, rate, transferId)
+	response := dto.TransferResponse{
+		TransferId: transferId,
+	}
+
+	if idempotencyKey != "" {
+		existing, err := reserveIdempotencyKey(r, idempotencyKey, requestHash, request.Sender, response)
+		if err != nil {
+			middleware.GetLogger(r).Error("Unable to reserve idempotency key", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to reserve idempotency key")
+			return
+		}
+
+		// a concurrent request with the same key got there first
+		if existing != nil {
+			replayIdempotentResponse(w, existing, requestHash)
+			return
+		}
+	}
+
+	// reserve the requested amount so the sender cannot spend it again before the outbox applies the transfer
+	ledgerRepository := middleware.GetLedgerRepository(r)
+	err = ledgerRepository.PlaceHold(model.Hold{
+		TransferId: transferId,
+		Account:    request.Sender,
+		Asset:      request.FromAsset,
+		Amount:     request.Amount,
+	})
+	if err != nil {
+		releaseIdempotencyKey(r, request.Sender, idempotencyKey)
+
+		if errors.Is(err, repository.ErrInsufficientBalance) {
+			writeError(w, http.StatusUnprocessableEntity, dto.InsufficientBalanceErrorCode, "not enough "+request.FromAsset+" balance for transfer")
+			return
+		}
+
+		middleware.GetLogger(r).Error("Unable to hold funds", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to hold funds")
+		return
+	}
+
+	event, err := event2.NewTransferCreated(request, fee, rate, transferId, idempotencyKey)
 	if err != nil {
-		http.Error(w, "Unable to create transfer event", http.StatusBadRequest)
+		releaseHold(r, transferId)
+		releaseIdempotencyKey(r, request.Sender, idempotencyKey)
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to create transfer event")
 		return
 	}
 
 	publisher := middleware.GetEventService(r)
 	err = publisher.PublishEvent(*event)
+	if errors.Is(err, eventbus.ErrPublishTimeout) {
+		// the event may still be delivered, so the hold and the idempotency key are kept - the client can look the
+		// transfer up, or retry with the idempotency key. The lock reaper releases the hold if the transfer never
+		// reaches the outbox.
+		middleware.GetLogger(r).Error("Transfer event delivery unconfirmed", zap.String("transfer_id", transferId.String()), zap.Error(err))
+		writeJSON(w, http.StatusAccepted, response)
+		return
+	}
+
 	if err != nil {
-		http.Error(w, "Unable publish transfer event", http.StatusBadRequest)
+		middleware.GetLogger(r).Error("Unable to publish transfer event", zap.Error(err))
+		releaseHold(r, transferId)
+		releaseIdempotencyKey(r, request.Sender, idempotencyKey)
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable publish transfer event")
 		return
 	}
 
-	response := dto.TransferResponse{
-		TransferId: transferId,
+	confirmIdempotencyKey(r, request.Sender, idempotencyKey)
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// releaseHold frees the funds held for a transfer that could not be published
+func releaseHold(r *http.Request, transferId uuid.UUID) {
+	if _, err := middleware.GetLedgerRepository(r).ReleaseHold(transferId); err != nil {
+		middleware.GetLogger(r).Error("Unable to release hold", zap.String("transfer_id", transferId.String()), zap.Error(err))
 	}
+}
+
+func GetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transferId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid transfer id")
+		return
+	}
+
+	transfer, err := middleware.GetTransferRepository(r).GetTransfer(transferId)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch transfer", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch transfer")
+		return
+	}
+
+	if transfer != nil {
+		writeJSON(w, http.StatusOK, toTransferStatusResponse(*transfer))
+		return
+	}
+
+	// the transfer may have been accepted, but not yet picked up by the outbox
+	createdEvent, err := middleware.GetTransferHistoryRepository(r).GetTransferEvent(transferId, event2.TransferCreatedEventType)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to fetch transfer history", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch transfer")
+		return
+	}
+
+	if createdEvent == nil {
+		writeError(w, http.StatusNotFound, dto.TransferNotFoundErrorCode, "Transfer not found: "+transferId.String())
+		return
+	}
+
+	created := event2.TransferCreated{}
+	if err := json.Unmarshal(createdEvent.Payload, &created); err != nil {
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to parse transfer event")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dto.TransferStatusResponse{
+		TransferId:      created.TransferId,
+		Status:          dto.AcceptedTransferStatus,
+		FromAsset:       created.FromAsset,
+		ToAsset:         created.ToAsset,
+		Sender:          created.Sender,
+		Recipient:       created.Recipient,
+		RequestedAmount: created.Amount,
+		Rate:            created.Rate,
+		CreatedAt:       time.UnixMilli(createdEvent.Timestamp).UTC(),
+	})
+}
+
+func CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transferId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, dto.InvalidRequestErrorCode, "Invalid transfer id")
+		return
+	}
+
+	transferRepository := middleware.GetTransferRepository(r)
+
+	cancelled, err := transferRepository.CancelTransfer(transferId)
+	if err != nil {
+		middleware.GetLogger(r).Error("Unable to cancel transfer", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to cancel transfer")
+		return
+	}
+
+	if cancelled == nil {
+		transfer, err := transferRepository.GetTransfer(transferId)
+		if err != nil {
+			middleware.GetLogger(r).Error("Unable to fetch transfer", zap.Error(err))
+			writeError(w, http.StatusInternalServerError, dto.InternalErrorCode, "Unable to fetch transfer")
+			return
+		}
+
+		if transfer == nil {
+			writeError(w, http.StatusNotFound, dto.TransferNotFoundErrorCode, "Transfer not found or not yet accepted by the outbox: "+transferId.String())
+			return
+		}
+
+		writeError(w, http.StatusConflict, dto.TransferNotCancellableErrorCode, "Transfer is "+string(transfer.TransferStatus)+" or already being processed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toTransferStatusResponse(*cancelled))
+}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Unable to write response", http.StatusInternalServerError)
+func toTransferStatusResponse(transfer model.Transfer) dto.TransferStatusResponse {
+	return dto.TransferStatusResponse{
+		TransferId:      transfer.TransferId,
+		Status:          string(transfer.TransferStatus),
+		FromAsset:       transfer.FromAsset,
+		ToAsset:         transfer.ToAsset,
+		Sender:          transfer.Sender,
+		Recipient:       transfer.Recipient,
+		RequestedAmount: transfer.RequestedAmount,
+		Fee:             &transfer.Fee,
+		NetAmount:       &transfer.NetAmount,
+		SentAmount:      transfer.SentAmount,
+		Rate:            transfer.Rate,
+		CreatedAt:       transfer.CreatedAt,
+		SentAt:          transfer.SentAt,
+		SettledAt:       transfer.SettledAt,
+		FailureReason:   transfer.FailureReason,
 	}
 }
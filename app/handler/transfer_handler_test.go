@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sphere-homework/app/config"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/middleware"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"sphere-homework/app/services"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTransferPipeline follows a transfer request from the handler through the transfer outbox and the ledger to the
+// transfer history, with the services running against an in-memory bus and store
+func TestTransferPipeline(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	bus := eventbus.NewMemoryBus()
+	store := repository.NewMemoryStore()
+
+	assets := repository.NewMemoryAssetRepository(store)
+	for _, code := range []string{"USD", "EUR"} {
+		_, err := assets.InsertAsset(model.Asset{Code: code, MinorUnits: 2, RoundingMode: model.HalfEvenRoundingMode})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, store.AssetRegistry().Refresh())
+
+	ledger := repository.NewMemoryLedgerRepository(store)
+	ledger.SetBalance("alice", "USD", decimal.NewFromInt(100))
+	ledger.SetBalance(repository.SystemAccount, "USD", decimal.Zero)
+
+	rates := repository.NewMemoryRateRepository(store)
+	assert.NoError(t, rates.UpsertRate("USD", "EUR", decimal.RequireFromString("0.5"), time.Now()))
+
+	fees := repository.NewMemoryFeeRepository(store)
+	fees.SetFee("EUR", decimal.RequireFromString("0.01"))
+
+	transfers := repository.NewMemoryTransferRepository(store)
+	history := repository.NewMemoryTransferHistoryRepository(store)
+
+	conf := config.Config{
+		IdempotencyKeyTtlSec:           60,
+		KafkaDeliveryTimeoutMs:         1000,
+		EventRelayPollFrequencyMs:      10,
+		TransferOutboxPollFrequencySec: 1,
+		TransferLockReaperFrequencySec: 1,
+		TransferLockLeaseSec:           30,
+		TransferWorkersPerAsset:        1,
+		TransferMaxAttempts:            1,
+	}
+
+	// payouts stay pending, the transfer is settled later
+	payoutRails := services.NewPayoutRails(services.NewSimulatedPayoutRail(services.SimulatedPayoutRailSetting{SettlementDelay: time.Hour}))
+
+	eventService := services.NewEventService(bus)
+	backgroundServices := []services.Service{
+		services.NewEventRelayService(logger, ctx, repository.NewMemoryEventOutboxRepository(store), &eventService, conf),
+		services.NewTransferService(bus.Subscriber(services.TransferServiceConsumer), logger, transfers, ledger, store.AssetRegistry(), payoutRails, nil, nil, ctx, conf),
+		services.NewTransferHistoryService(ctx, bus.Subscriber(services.TransferHistoryServiceConsumer), logger, history, nil),
+	}
+
+	for _, service := range backgroundServices {
+		assert.NoError(t, service.Init())
+	}
+
+	defer func() {
+		for i := len(backgroundServices) - 1; i >= 0; i-- {
+			assert.NoError(t, backgroundServices[i].Stop(ctx))
+		}
+	}()
+
+	servicesContext := &middleware.ServicesContext{
+		EventService:              &eventService,
+		RateRepository:            rates,
+		LedgerRepository:          ledger,
+		FeeRepository:             fees,
+		AssetRepository:           assets,
+		AssetRegistry:             store.AssetRegistry(),
+		Validator:                 services.NewTransferValidator(assets, ledger, conf),
+		IdempotencyKeyRepository:  repository.NewMemoryIdempotencyKeyRepository(store),
+		TransferRepository:        transfers,
+		TransferHistoryRepository: history,
+	}
+
+	body := `{"from_asset":"USD","to_asset":"EUR","amount":"10","sender":"alice","recipient":"bob"}`
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/transfer", strings.NewReader(body))
+	request.Header.Set(IdempotencyKeyHeader, "pipeline")
+	request = request.WithContext(context.WithValue(context.WithValue(request.Context(), middleware.ConfigKey, &conf), middleware.ServicesContextKey, servicesContext))
+
+	recorder := httptest.NewRecorder()
+	TransferHandler(recorder, request)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	response := dto.TransferResponse{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	assert.Eventually(t, func() bool {
+		sent, err := history.GetTransferEvent(response.TransferId, event.TransferSentEventType)
+		return err == nil && sent != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	transfer, err := transfers.GetTransfer(response.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.SentTransferStatus, transfer.TransferStatus)
+	assert.Equal(t, "0.1", transfer.Fee.String())
+	assert.Equal(t, "4.95", transfer.SentAmount.String())
+
+	created, err := history.GetTransferEvent(response.TransferId, event.TransferCreatedEventType)
+	assert.NoError(t, err)
+	assert.NotNil(t, created)
+
+	now := time.Now().Add(time.Minute)
+
+	statement, err := ledger.GetStatement("alice", now.Add(-time.Hour), now, nil)
+	assert.NoError(t, err)
+	assert.Len(t, statement.Assets, 1)
+	assert.Len(t, statement.Assets[0].Entries, 1)
+	assert.Equal(t, "-10", statement.Assets[0].Entries[0].Amount.String())
+	assert.Equal(t, "90", statement.Assets[0].ClosingBalance.String())
+
+	balance, err := ledger.GetAccountBalance("bob", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, "4.95", balance.Balance.String())
+
+	balance, err = ledger.GetAccountBalance(repository.SystemAccount, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1", balance.Balance.String())
+
+	// the hold placed by the handler was captured by the ledger transfer
+	balance, err = ledger.GetAccountBalance("alice", "USD")
+	assert.NoError(t, err)
+	assert.True(t, balance.Held.IsZero())
+}
+
+// timedOutPublisher accepts messages without ever confirming their delivery
+type timedOutPublisher struct {
+	mu       sync.Mutex
+	messages []eventbus.Message
+}
+
+func (p *timedOutPublisher) Publish(msg eventbus.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = append(p.messages, msg)
+	return eventbus.ErrPublishTimeout
+}
+
+func (p *timedOutPublisher) PublishAsync(msg eventbus.Message, callback func(error)) error {
+	go callback(p.Publish(msg))
+	return nil
+}
+
+func TestTransferHoldIsReleasedWhenEventDeliveryTimedOut(t *testing.T) {
+	store := repository.NewMemoryStore()
+
+	assets := repository.NewMemoryAssetRepository(store)
+	_, err := assets.InsertAsset(model.Asset{Code: "USD", MinorUnits: 2, RoundingMode: model.HalfEvenRoundingMode})
+	assert.NoError(t, err)
+	assert.NoError(t, store.AssetRegistry().Refresh())
+
+	ledger := repository.NewMemoryLedgerRepository(store)
+	ledger.SetBalance("alice", "USD", decimal.NewFromInt(100))
+
+	rates := repository.NewMemoryRateRepository(store)
+	assert.NoError(t, rates.UpsertRate("USD", "USD", decimal.NewFromInt(1), time.Now()))
+
+	fees := repository.NewMemoryFeeRepository(store)
+	fees.SetFee("USD", decimal.Zero)
+
+	conf := config.Config{IdempotencyKeyTtlSec: 60, TransferHoldExpirySec: 600}
+
+	publisher := &timedOutPublisher{}
+	eventService := services.NewEventService(publisher)
+
+	servicesContext := &middleware.ServicesContext{
+		EventService:             &eventService,
+		RateRepository:           rates,
+		LedgerRepository:         ledger,
+		FeeRepository:            fees,
+		AssetRepository:          assets,
+		AssetRegistry:            store.AssetRegistry(),
+		Validator:                services.NewTransferValidator(assets, ledger, conf),
+		IdempotencyKeyRepository: repository.NewMemoryIdempotencyKeyRepository(store),
+	}
+
+	body := `{"from_asset":"USD","to_asset":"USD","amount":"40","sender":"alice","recipient":"bob"}`
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/transfer", strings.NewReader(body))
+	request = request.WithContext(context.WithValue(context.WithValue(request.Context(), middleware.ConfigKey, &conf), middleware.ServicesContextKey, servicesContext))
+	request = request.WithContext(context.WithValue(request.Context(), middleware.LoggerKey, zap.NewNop()))
+
+	recorder := httptest.NewRecorder()
+	TransferHandler(recorder, request)
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Len(t, publisher.messages, 1)
+
+	// the event may still be delivered, so the funds stay held
+	balance, err := ledger.GetAccountBalance("alice", "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "40", balance.Held.String())
+
+	released, err := ledger.ReleaseExpiredHolds(time.Duration(conf.TransferHoldExpirySec)*time.Second, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, released)
+
+	// the event never reached the transfer outbox, so the hold is released once it expired
+	later := time.Now().Add(time.Duration(conf.TransferHoldExpirySec+1) * time.Second)
+	store.SetClock(func() time.Time { return later })
+
+	released, err = ledger.ReleaseExpiredHolds(time.Duration(conf.TransferHoldExpirySec)*time.Second, 10)
+	assert.NoError(t, err)
+	assert.Len(t, released, 1)
+
+	balance, err = ledger.GetAccountBalance("alice", "USD")
+	assert.NoError(t, err)
+	assert.True(t, balance.Held.IsZero())
+	assert.Equal(t, "100", balance.Balance.String())
+}
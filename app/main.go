@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/gorilla/mux"
@@ -9,11 +10,15 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 	"go.uber.org/zap"
 	"net/http"
+	"os/signal"
 	"sphere-homework/app/config"
+	"sphere-homework/app/eventbus"
 	"sphere-homework/app/handler"
 	"sphere-homework/app/middleware"
 	"sphere-homework/app/repository"
 	"sphere-homework/app/services"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -33,7 +38,8 @@ func main() {
 
 	// setup kafka producer
 	producer, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": conf.KafkaBootstrapServers,
+		"bootstrap.servers":  conf.KafkaBootstrapServers,
+		"message.timeout.ms": conf.KafkaDeliveryTimeoutMs,
 	})
 	if err != nil {
 		logger.Fatal("failed to create kafka producer", zap.Error(err))
@@ -43,23 +49,44 @@ func main() {
 	// setup consumers
 	transferServiceConsumer, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers": conf.KafkaBootstrapServers,
-		"group.id":          "sphere-transfer-service-consumer",
+		"group.id":          services.TransferServiceConsumer,
 		"auto.offset.reset": "earliest",
+		// offsets are committed once a message was handled or dead-lettered, so the consumers get at-least-once delivery
+		"enable.auto.commit": false,
 	})
 	if err != nil {
 		logger.Fatal("failed to create transfer service kafka consumer", zap.Error(err))
 	}
-	defer transferServiceConsumer.Close()
 
 	transferHistoryServiceConsumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": conf.KafkaBootstrapServers,
-		"group.id":          "sphere-transfer-history-service-consumer",
-		"auto.offset.reset": "earliest",
+		"bootstrap.servers":  conf.KafkaBootstrapServers,
+		"group.id":           services.TransferHistoryServiceConsumer,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
 	})
 	if err != nil {
 		logger.Fatal("failed to create transfer history service kafka consumer", zap.Error(err))
 	}
-	defer transferHistoryServiceConsumer.Close()
+
+	settlementServiceConsumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  conf.KafkaBootstrapServers,
+		"group.id":           services.SettlementServiceConsumer,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		logger.Fatal("failed to create settlement service kafka consumer", zap.Error(err))
+	}
+
+	deadLetterServiceConsumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  conf.KafkaBootstrapServers,
+		"group.id":           services.DeadLetterServiceConsumer,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		logger.Fatal("failed to create dead letter service kafka consumer", zap.Error(err))
+	}
 
 	// setup db
 	pool, err := pgxpool.New(context.Background(), conf.DbUrl)
@@ -69,81 +96,154 @@ func main() {
 	defer pool.Close()
 
 	// setup repositories
+	assetRepository := repository.NewAssetRepository(pool, ctx)
+	assetRegistry := repository.NewAssetRegistry(&assetRepository)
+	err = assetRegistry.Refresh()
+	if err != nil {
+		logger.Fatal("failed to load assets", zap.Error(err))
+	}
+
 	transferRepository := repository.NewTransferRepository(pool, ctx)
 	exchangeRateRepository := repository.NewRateRepository(pool, ctx, logger)
-	ledgerRepository := repository.NewLedgerRepository(pool, ctx, logger)
+	ledgerRepository := repository.NewLedgerRepository(pool, ctx, logger, assetRegistry)
 	feeRepository := repository.NewFeeRepository(pool, ctx)
 	transferHistoryRepository := repository.NewTransferHistoryRepository(pool, ctx)
+	idempotencyKeyRepository := repository.NewIdempotencyKeyRepository(pool, ctx)
+	eventOutboxRepository := repository.NewEventOutboxRepository(pool, ctx)
+	deadLetterRepository := repository.NewDeadLetterRepository(pool, ctx)
+	poolRebalanceSettingRepository := repository.NewPoolRebalanceSettingRepository(pool, ctx)
 
 	// setup services
+	publisher := eventbus.NewKafkaPublisher(producer, logger, time.Duration(conf.KafkaDeliveryTimeoutMs)*time.Millisecond)
+	publisher.Init()
 
-	// hard-code settings for now
-	poolBalancerConfig := map[string]services.PoolReBalancerSetting{
-		// this means if we are experiencing a lot of withdrawals and our usd amount is less than 10000, then we need to re-balance
-		"USD": {
-			ImbalanceThreshold: 0.7,
-			MinimumBalance:     400000,
-			TopUpAmount:        15000,
-		},
-
-		"EUR": {
-			ImbalanceThreshold: 0.2,
-			MinimumBalance:     5000,
-			TopUpAmount:        10000,
-		},
-
-		"JPY": {
-			ImbalanceThreshold: 0.3,
-			MinimumBalance:     500000,
-			TopUpAmount:        700000,
-		},
-
-		"GBP": {
-			ImbalanceThreshold: 0.1,
-			MinimumBalance:     100000,
-			TopUpAmount:        120000,
-		},
-
-		"AUD": {
-			ImbalanceThreshold: 0.2,
-			MinimumBalance:     300000,
-			TopUpAmount:        320000,
-		},
-	}
-
-	eventService := services.NewEventService(producer)
-	transferService := services.NewTransferService(transferServiceConsumer, logger, &transferRepository, &ledgerRepository, &eventService, ctx, conf)
-	transferHistoryService := services.NewTransferHistoryService(ctx, transferHistoryServiceConsumer, logger, &transferHistoryRepository)
-	poolRebalancerService := services.NewPoolRebalancerService(logger, ctx, &exchangeRateRepository, &transferRepository, &ledgerRepository, &eventService, conf, poolBalancerConfig)
-
-	err = transferService.Init()
-	if err != nil {
-		logger.Fatal("failed to initialize transfer service", zap.Error(err))
+	eventService := services.NewEventService(publisher)
+
+	// every asset pays out through the simulated rail for now - register real providers per destination asset here
+	simulatedRailSetting := services.SimulatedPayoutRailSetting{
+		SettlementDelay: time.Duration(conf.SimulatedRailSettlementDelaySec) * time.Second,
+		RejectionRate:   conf.SimulatedRailRejectionRate,
+		FailureRate:     conf.SimulatedRailFailureRate,
+		TimeoutRate:     conf.SimulatedRailTimeoutRate,
 	}
 
-	err = transferHistoryService.Init()
-	if err != nil {
-		logger.Fatal("failed to initialize transfer history service", zap.Error(err))
+	payoutRails := services.NewPayoutRails(services.NewSimulatedPayoutRail(simulatedRailSetting))
+
+	for asset, latencyMs := range conf.SimulatedRailSubmitLatencyMs {
+		setting := simulatedRailSetting
+		setting.SubmitLatency = time.Duration(latencyMs) * time.Millisecond
+		payoutRails.Register(asset, services.NewSimulatedPayoutRail(setting))
+	}
+
+	transferValidator := services.NewTransferValidator(&assetRepository, &ledgerRepository, conf)
+	deadLetterService := services.NewDeadLetterService(publisher, eventbus.NewKafkaSubscriber(deadLetterServiceConsumer), logger, &deadLetterRepository, ctx)
+	transferHistoryService := services.NewTransferHistoryService(ctx, eventbus.NewKafkaSubscriber(transferHistoryServiceConsumer), logger, &transferHistoryRepository, deadLetterService)
+	settlementService := services.NewSettlementService(eventbus.NewKafkaSubscriber(settlementServiceConsumer), logger, &transferRepository, payoutRails, deadLetterService, ctx, conf)
+	transferService := services.NewTransferService(eventbus.NewKafkaSubscriber(transferServiceConsumer), logger, &transferRepository, &ledgerRepository, assetRegistry, payoutRails, settlementService, deadLetterService, ctx, conf)
+	eventRelayService := services.NewEventRelayService(logger, ctx, &eventOutboxRepository, &eventService, conf)
+	poolRebalancerService := services.NewPoolRebalancerService(logger, ctx, &exchangeRateRepository, &transferRepository, &ledgerRepository, &eventService, conf, &poolRebalanceSettingRepository)
+
+	// services are stopped in the reverse order - the event relay last, so it publishes the events of the drained transfers
+	backgroundServices := []services.Service{
+		eventRelayService,
+		deadLetterService,
+		transferService,
+		settlementService,
+		transferHistoryService,
+		poolRebalancerService,
 	}
 
-	poolRebalancerService.Init()
+	for _, service := range backgroundServices {
+		if err := service.Init(); err != nil {
+			logger.Fatal("failed to initialize "+service.Name(), zap.Error(err))
+		}
+	}
 
 	// setup http handlers
 	r := mux.NewRouter()
 	r.Use(middleware.InjectorMiddleware(logger, &conf, &middleware.ServicesContext{
-		EventService:     &eventService,
-		RateRepository:   &exchangeRateRepository,
-		LedgerRepository: &ledgerRepository,
-		FeeRepository:    &feeRepository,
+		EventService:              &eventService,
+		RateRepository:            &exchangeRateRepository,
+		LedgerRepository:          &ledgerRepository,
+		FeeRepository:             &feeRepository,
+		AssetRepository:           &assetRepository,
+		AssetRegistry:             assetRegistry,
+		Validator:                 transferValidator,
+		IdempotencyKeyRepository:  &idempotencyKeyRepository,
+		TransferRepository:        &transferRepository,
+		TransferHistoryRepository: &transferHistoryRepository,
+		SettlementService:         settlementService,
+		DeadLetterRepository:      &deadLetterRepository,
+		DeadLetterService:         deadLetterService,
+		Services:                  backgroundServices,
 	}))
 	r.Use(middleware.LoggerMiddleware())
 
 	r.HandleFunc("/api/v1/transfer", handler.TransferHandler).Methods("POST")
+	r.HandleFunc("/api/v1/transfer/{id}", handler.GetTransferHandler).Methods("GET")
+	r.HandleFunc("/api/v1/transfer/{id}/cancel", handler.CancelTransferHandler).Methods("POST")
+	r.HandleFunc("/api/v1/settlement/callback", handler.SettlementCallbackHandler).Methods("POST")
+	r.HandleFunc("/api/v1/accounts/{account}/transfers", handler.ListAccountTransfersHandler).Methods("GET")
+	r.HandleFunc("/api/v1/accounts/{account}/balances", handler.GetAccountBalancesHandler).Methods("GET")
+	r.HandleFunc("/api/v1/accounts/{account}/balances/{asset}", handler.GetAccountAssetBalanceHandler).Methods("GET")
+	r.HandleFunc("/api/v1/accounts/{account}/statement", handler.GetAccountStatementHandler).Methods("GET")
 	r.HandleFunc("/api/v1/exchange-rate", handler.ExchangeRateHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/assets", handler.ListAssetsHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/assets", handler.CreateAssetHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/assets/{code}/disable", handler.DisableAssetHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/transfers/{id}/reverse", handler.ReverseTransferHandler).Methods("POST")
+	r.HandleFunc("/api/v1/admin/dead-letters", handler.ListDeadLettersHandler).Methods("GET")
+	r.HandleFunc("/api/v1/admin/dead-letters/{id}/replay", handler.ReplayDeadLetterHandler).Methods("POST")
+	r.HandleFunc("/health", handler.HealthHandler).Methods("GET")
+	r.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", conf.Port),
+		Handler: r,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting sphere transaction server", zap.Int("port", conf.Port))
+		serverErr <- server.ListenAndServe()
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	var failure error
+	select {
+	case <-signals.Done():
+		logger.Info("Received shutdown signal")
+	case failure = <-serverErr:
+		logger.Error("http server stopped", zap.Error(failure))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(conf.ShutdownTimeoutSec)*time.Second)
+	defer cancel()
+
+	// stop accepting requests, and wait for the in-flight ones
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down http server", zap.Error(err))
+	}
+
+	for i := len(backgroundServices) - 1; i >= 0; i-- {
+		service := backgroundServices[i]
+		if err := service.Stop(shutdownCtx); err != nil {
+			logger.Error("failed to stop "+service.Name(), zap.Error(err))
+			continue
+		}
+		logger.Info("Stopped " + service.Name())
+	}
+
+	// wait for the delivery of the events the stopped services handed to the producer
+	deadline, _ := shutdownCtx.Deadline()
+	if remaining := producer.Flush(max(int(time.Until(deadline).Milliseconds()), 0)); remaining > 0 {
+		logger.Error("failed to flush kafka producer", zap.Int("remaining", remaining))
+	}
 
-	logger.Info("Starting sphere transaction server", zap.Int("port", conf.Port))
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", conf.Port), r); err != nil {
-		logger.Fatal("failed to start http server", zap.Error(err))
+	if failure != nil {
+		logger.Fatal("failed to start http server", zap.Error(failure))
 	}
 	logger.Info("Exiting sphere transaction server", zap.Int("port", conf.Port))
 }
@@ -0,0 +1,21 @@
+package metrics
+
+import "expvar"
+
+// metrics are published with expvar, and served as json on /debug/vars
+
+// ReclaimedTransferLocks counts the expired outbox processor locks taken back by the lock reaper, by model.LockReclaim
+var ReclaimedTransferLocks = expvar.NewMap("transfer_outbox_reclaimed_locks")
+
+// ReleasedExpiredHolds counts the holds released by the lock reaper because their transfer never reached the outbox
+var ReleasedExpiredHolds = expvar.NewInt("transfer_released_expired_holds")
+
+// TransferRetries counts the transfer attempts that failed with a transient error and were scheduled for a retry
+var TransferRetries = expvar.NewInt("transfer_outbox_retries")
+
+// PublishedMessages counts the messages handed to the kafka producer by their delivery: delivered, failed or timed_out
+// if the delivery report did not arrive in time
+var PublishedMessages = expvar.NewMap("kafka_published_messages")
+
+// KafkaProducerErrors counts the errors the kafka producer reported that are not about a message, e.g. all brokers down
+var KafkaProducerErrors = expvar.NewInt("kafka_producer_errors")
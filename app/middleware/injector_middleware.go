@@ -31,7 +31,7 @@ func InjectorMiddleware(
 	}
 }
 
-func GetFeeRepository(r *http.Request) *repository.FeeRepository {
+func GetFeeRepository(r *http.Request) repository.FeeRepository {
 	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
 	if !ok {
 		return nil
@@ -39,7 +39,7 @@ func GetFeeRepository(r *http.Request) *repository.FeeRepository {
 	return s.FeeRepository
 }
 
-func GetLedgerRepository(r *http.Request) *repository.LedgerRepository {
+func GetLedgerRepository(r *http.Request) repository.LedgerRepository {
 	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
 	if !ok {
 		return nil
@@ -47,7 +47,87 @@ func GetLedgerRepository(r *http.Request) *repository.LedgerRepository {
 	return s.LedgerRepository
 }
 
-func GetRateRepository(r *http.Request) *repository.RateRepository {
+func GetAssetRepository(r *http.Request) repository.AssetRepository {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.AssetRepository
+}
+
+func GetAssetRegistry(r *http.Request) *repository.AssetRegistry {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.AssetRegistry
+}
+
+func GetTransferValidator(r *http.Request) *services.TransferValidator {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.Validator
+}
+
+func GetIdempotencyKeyRepository(r *http.Request) repository.IdempotencyKeyRepository {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.IdempotencyKeyRepository
+}
+
+func GetTransferRepository(r *http.Request) repository.TransferRepository {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.TransferRepository
+}
+
+func GetTransferHistoryRepository(r *http.Request) repository.TransferHistoryRepository {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.TransferHistoryRepository
+}
+
+func GetSettlementService(r *http.Request) *services.SettlementService {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.SettlementService
+}
+
+func GetDeadLetterRepository(r *http.Request) repository.DeadLetterRepository {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.DeadLetterRepository
+}
+
+func GetDeadLetterService(r *http.Request) *services.DeadLetterService {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.DeadLetterService
+}
+
+func GetServices(r *http.Request) []services.Service {
+	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
+	if !ok {
+		return nil
+	}
+	return s.Services
+}
+
+func GetRateRepository(r *http.Request) repository.RateRepository {
 	s, ok := r.Context().Value(ServicesContextKey).(*ServicesContext)
 	if !ok {
 		return nil
@@ -6,8 +6,18 @@ import (
 )
 
 type ServicesContext struct {
-	EventService     *services.EventService
-	RateRepository   *repository.RateRepository
-	LedgerRepository *repository.LedgerRepository
-	FeeRepository    *repository.FeeRepository
+	EventService              *services.EventService
+	RateRepository            repository.RateRepository
+	LedgerRepository          repository.LedgerRepository
+	FeeRepository             repository.FeeRepository
+	AssetRepository           repository.AssetRepository
+	AssetRegistry             *repository.AssetRegistry
+	Validator                 *services.TransferValidator
+	IdempotencyKeyRepository  repository.IdempotencyKeyRepository
+	TransferRepository        repository.TransferRepository
+	TransferHistoryRepository repository.TransferHistoryRepository
+	SettlementService         *services.SettlementService
+	DeadLetterRepository      repository.DeadLetterRepository
+	DeadLetterService         *services.DeadLetterService
+	Services                  []services.Service // background services reported by the health check
 }
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type Asset struct {
+	Code         string
+	MinorUnits   int32 // minor-unit exponent, e.g. 2 for USD (cents), 0 for JPY
+	RoundingMode RoundingMode
+	Enabled      bool // disabled assets can no longer be transferred, but in-flight transfers are still settled
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Round rounds the amount to the asset's minor unit using the asset's rounding mode
+func (a Asset) Round(amount decimal.Decimal) decimal.Decimal {
+	return Round(amount, a.MinorUnits, a.RoundingMode)
+}
@@ -1,19 +1,32 @@
 package model
 
+import "github.com/shopspring/decimal"
+
 type LedgerBalance struct {
 	Asset   string
-	Amount  float64
-	Inflow  float64
-	Outflow float64
+	Amount  decimal.Decimal
+	Inflow  decimal.Decimal
+	Outflow decimal.Decimal
 }
 
 // GetImbalanceRatio is used to quantify how far a pool’s liquidity flow is from being balanced.
 // A positive imbalance ratio means that the pool is loosing liquidity (i.e. more withdrawals are happening)
 // A negative imbalance ratio mens that the pool is increasing its liquidity (i.e. more deposits are happening)
 func (l *LedgerBalance) GetImbalanceRatio() float64 {
-	if l.Amount == 0 {
+	if l.Amount.IsZero() {
 		return 0
 	}
 
-	return (l.Outflow - l.Inflow) / l.Amount
+	ratio, _ := l.Outflow.Sub(l.Inflow).Div(l.Amount).Float64()
+
+	return ratio
+}
+
+// AccountBalance is the balance of an account's asset as seen by the account holder
+type AccountBalance struct {
+	Asset           string
+	Balance         decimal.Decimal // balance on the ledger
+	Held            decimal.Decimal // funds reserved for accepted transfers that are not yet applied to the ledger
+	PendingOutgoing decimal.Decimal // sum of the account's transfers in the outbox that are not yet applied to the ledger
+	Available       decimal.Decimal // balance less held funds
 }
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// DeadLetterHeader is a kafka message header, kept as is so a replayed message carries the headers it was sent with
+type DeadLetterHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// DeadLetter is a message a consumer failed to decode or process, with the error it failed with
+type DeadLetter struct {
+	Id         int64
+	CreatedAt  time.Time
+	Consumer   string // consumer group that failed the message
+	Error      string
+	Topic      string // topic, partition and offset the message was consumed from
+	Partition  int32
+	Offset     int64
+	Key        []byte
+	Payload    []byte
+	Headers    []DeadLetterHeader
+	ReplayedAt *time.Time
+}
+
+// DeadLetterFilter selects dead letters, most recent first
+type DeadLetterFilter struct {
+	Consumer *string
+	Replayed *bool
+	BeforeId *int64
+	Limit    int
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type HoldStatus string
+
+const (
+	HeldHoldStatus     HoldStatus = "HELD"     // funds are reserved against the sender's balance
+	CapturedHoldStatus HoldStatus = "CAPTURED" // the transfer was applied to the ledger
+	ReleasedHoldStatus HoldStatus = "RELEASED" // the transfer failed or was cancelled, funds are available again
+)
+
+// Hold reserves a transfer's requested amount against the sender's balance from acceptance until it is applied to the ledger
+type Hold struct {
+	TransferId uuid.UUID
+	Account    string
+	Asset      string
+	Amount     decimal.Decimal
+	Status     HoldStatus
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type IdempotencyKey struct {
+	Key         string
+	Sender      string
+	RequestHash string // hash of the request the key was first used with
+	TransferId  uuid.UUID
+	Response    []byte // response returned to the client, replayed on retries
+	StatusCode  int    // status code of the response
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
@@ -1,18 +1,23 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
 
 type LedgerEntryType string
 
 const (
 	FeeLedgerEntryType      LedgerEntryType = "FEE"
 	TransferLedgerEntryType LedgerEntryType = "TRANSFER"
+	RoundingLedgerEntryType LedgerEntryType = "ROUNDING"
+	ReversalLedgerEntryType LedgerEntryType = "REVERSAL" // undoes an entry of the same transfer
 )
 
 type LedgerEntry struct {
 	TransferId uuid.UUID
 	Account    string
 	Asset      string
-	Amount     float64
+	Amount     decimal.Decimal
 	Type       LedgerEntryType
 }
@@ -0,0 +1,43 @@
+package model
+
+import "github.com/shopspring/decimal"
+
+type RoundingMode string
+
+const (
+	HalfEvenRoundingMode RoundingMode = "HALF_EVEN"
+	HalfUpRoundingMode   RoundingMode = "HALF_UP"
+	DownRoundingMode     RoundingMode = "DOWN"
+	UpRoundingMode       RoundingMode = "UP"
+)
+
+// StorageScale is the number of decimal places persisted by the NUMERIC(40, 30) columns
+const StorageScale int32 = 30
+
+// Round rounds the amount to the given number of decimal places using the rounding mode
+func Round(amount decimal.Decimal, scale int32, mode RoundingMode) decimal.Decimal {
+	switch mode {
+	case HalfUpRoundingMode:
+		return amount.Round(scale)
+	case DownRoundingMode:
+		return amount.RoundDown(scale)
+	case UpRoundingMode:
+		return amount.RoundUp(scale)
+	default:
+		return amount.RoundBank(scale)
+	}
+}
+
+// RoundToStorage trims the amount to what the db can store
+func RoundToStorage(amount decimal.Decimal) decimal.Decimal {
+	return Round(amount, StorageScale, HalfEvenRoundingMode)
+}
+
+func IsValidRoundingMode(mode RoundingMode) bool {
+	switch mode {
+	case HalfEvenRoundingMode, HalfUpRoundingMode, DownRoundingMode, UpRoundingMode:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAssetRoundsToMinorUnits(t *testing.T) {
+	amount := decimal.RequireFromString("1234.5650")
+
+	usd := Asset{Code: "USD", MinorUnits: 2, RoundingMode: HalfEvenRoundingMode}
+	jpy := Asset{Code: "JPY", MinorUnits: 0, RoundingMode: HalfEvenRoundingMode}
+
+	assert.Equal(t, "1234.56", usd.Round(amount).String())
+	assert.Equal(t, "1235", jpy.Round(amount).String())
+}
+
+func TestRoundingModes(t *testing.T) {
+	amount := decimal.RequireFromString("10.125")
+
+	assert.Equal(t, "10.12", Round(amount, 2, HalfEvenRoundingMode).String())
+	assert.Equal(t, "10.13", Round(amount, 2, HalfUpRoundingMode).String())
+	assert.Equal(t, "10.12", Round(amount, 2, DownRoundingMode).String())
+	assert.Equal(t, "10.13", Round(amount, 2, UpRoundingMode).String())
+}
+
+func TestRoundToStorageKeepsStoragePrecision(t *testing.T) {
+	amount := decimal.RequireFromString("0.123456789")
+
+	assert.Equal(t, "0.123456789", RoundToStorage(amount).String())
+}
@@ -0,0 +1,28 @@
+package model
+
+import "github.com/google/uuid"
+
+type PayoutStatus string
+
+const (
+	PendingPayoutStatus   PayoutStatus = "PENDING"
+	CompletedPayoutStatus PayoutStatus = "COMPLETED"
+	FailedPayoutStatus    PayoutStatus = "FAILED"
+)
+
+// Payout is a transfer as seen by the payout rail it was submitted to
+type Payout struct {
+	TransferId    uuid.UUID
+	Reference     string // the rail's own id for the payout
+	Status        PayoutStatus
+	FailureReason string
+}
+
+// Settlement returns the settlement of a completed or failed payout
+func (p Payout) Settlement() Settlement {
+	if p.Status == FailedPayoutStatus {
+		return Settlement{TransferId: p.TransferId, Status: FailedTransferStatus, FailureReason: p.FailureReason}
+	}
+
+	return Settlement{TransferId: p.TransferId, Status: CompletedTransferStatus}
+}
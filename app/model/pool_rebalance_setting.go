@@ -0,0 +1,12 @@
+package model
+
+import "github.com/shopspring/decimal"
+
+// PoolRebalanceSetting is when and by how much the system pool rebalancer tops up the system account of an asset
+type PoolRebalanceSetting struct {
+	Asset                   string
+	ImbalanceThreshold      float64         // if it exceeds the current threshold, it means we are experiencing some demand (either withdrawals for positive value, or deposits for negative value) and may need to trigger a re-balance if balance is also less than minimum balance
+	MinimumBalance          decimal.Decimal // required minimum balance
+	TopUpAmount             decimal.Decimal // the amount to be added to this pool if a re-balancing is needed
+	RequiredBalanceForTopUp decimal.Decimal // the asset needs have this amount of balance before we transfer out balance from this asset
+}
@@ -0,0 +1,9 @@
+package model
+
+// Reversal is a transfer whose ledger entries were undone by compensating entries, which refunds the sender the
+// principal and the fee
+type Reversal struct {
+	Transfer Transfer
+	Reason   string
+	Entries  []LedgerEntry // the compensating entries
+}
@@ -0,0 +1,14 @@
+package model
+
+import "github.com/google/uuid"
+
+// Settlement is the rail's confirmation of a sent transfer - Status is either COMPLETED or FAILED
+type Settlement struct {
+	TransferId    uuid.UUID
+	Status        TransferStatus
+	FailureReason string
+}
+
+func IsValidSettlementStatus(status TransferStatus) bool {
+	return status == CompletedTransferStatus || status == FailedTransferStatus
+}
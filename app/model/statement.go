@@ -0,0 +1,51 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type StatementEntry struct {
+	CreatedAt      time.Time
+	TransferId     uuid.UUID
+	Type           LedgerEntryType
+	Asset          string
+	Amount         decimal.Decimal
+	RunningBalance decimal.Decimal // balance of the asset after applying this entry
+}
+
+type AssetStatement struct {
+	Asset          string
+	OpeningBalance decimal.Decimal
+	ClosingBalance decimal.Decimal
+	Entries        []StatementEntry
+}
+
+// Statement lists the ledger entries of an account from From (inclusive) to To (exclusive)
+type Statement struct {
+	Account string
+	From    time.Time
+	To      time.Time
+	Assets  []AssetStatement
+}
+
+// NewAssetStatement computes the running and closing balances of the entries, which must be in chronological order
+func NewAssetStatement(asset string, openingBalance decimal.Decimal, entries []StatementEntry) AssetStatement {
+	statement := AssetStatement{
+		Asset:          asset,
+		OpeningBalance: openingBalance,
+		Entries:        []StatementEntry{},
+	}
+
+	balance := openingBalance
+	for _, entry := range entries {
+		balance = balance.Add(entry.Amount)
+		entry.RunningBalance = balance
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	statement.ClosingBalance = balance
+
+	return statement
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewAssetStatementComputesRunningBalance(t *testing.T) {
+	transferId := uuid.New()
+	entries := []StatementEntry{
+		{TransferId: transferId, Type: TransferLedgerEntryType, Asset: "USD", Amount: decimal.RequireFromString("-100.00")},
+		{TransferId: uuid.New(), Type: TransferLedgerEntryType, Asset: "USD", Amount: decimal.RequireFromString("25.50")},
+		{TransferId: uuid.New(), Type: FeeLedgerEntryType, Asset: "USD", Amount: decimal.RequireFromString("1.25")},
+	}
+
+	statement := NewAssetStatement("USD", decimal.NewFromInt(1000), entries)
+
+	assert.Equal(t, "1000", statement.OpeningBalance.String())
+	assert.Equal(t, "900", statement.Entries[0].RunningBalance.String())
+	assert.Equal(t, "925.5", statement.Entries[1].RunningBalance.String())
+	assert.Equal(t, "926.75", statement.Entries[2].RunningBalance.String())
+	assert.Equal(t, "926.75", statement.ClosingBalance.String())
+	assert.Equal(t, transferId, statement.Entries[0].TransferId)
+}
+
+func TestNewAssetStatementWithoutEntries(t *testing.T) {
+	statement := NewAssetStatement("JPY", decimal.NewFromInt(500), nil)
+
+	assert.Empty(t, statement.Entries)
+	assert.Equal(t, "500", statement.ClosingBalance.String())
+}
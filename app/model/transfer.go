@@ -2,6 +2,7 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"time"
 )
 
@@ -25,17 +26,64 @@ type Transfer struct {
 	TransferId      uuid.UUID
 	CreatedAt       time.Time
 	SentAt          *time.Time
+	SettledAt       *time.Time // set once the rail confirmed or rejected the sent transfer
 	FromAsset       string
 	ToAsset         string
-	RequestedAmount float64
-	NetAmount       float64  // amount less fees
-	SentAmount      *float64 // amount sent to the user, which is the net amount multiplied by the rate
-	Fee             float64  // fee charged, in the same currency as the RequestedAmount
-	Rate            float64
+	RequestedAmount decimal.Decimal
+	NetAmount       decimal.Decimal  // amount less fees
+	SentAmount      *decimal.Decimal // amount sent to the user, which is the net amount multiplied by the rate
+	Fee             decimal.Decimal  // fee charged, in the same currency as the RequestedAmount
+	Rate            decimal.Decimal
 	Sender          string
 	Recipient       string
 	TransferStatus  TransferStatus
 	FailureReason   *string
 	TransferType    TransferType
 	LockId          *uuid.UUID
+	LockedAt        *time.Time
+	LockExpiresAt   *time.Time // the lock is reclaimed by the lock reaper once it expires
+	AttemptCount    int        // number of times an outbox processor claimed the transfer
+	NextAttemptAt   *time.Time // a transfer that failed with a transient error is not claimed again before this time
+	LastError       *string    // error of the last failed attempt
+	IdempotencyKey  *string    // client supplied key, at most one transfer is recorded per key
+}
+
+// LockReclaim is what the lock reaper did with an expired outbox processor lock
+type LockReclaim string
+
+const (
+	NoLockReclaim        LockReclaim = "none"      // the lock was released or renewed in the meantime
+	ReleasedLockReclaim  LockReclaim = "released"  // the ledger was not applied, the transfer is processed again
+	RecoveredLockReclaim LockReclaim = "recovered" // the ledger was applied, the transfer is recorded as sent
+)
+
+// TransferCursor is the position of a transfer in a listing ordered by created_at, transfer_id descending
+type TransferCursor struct {
+	CreatedAt  time.Time
+	TransferId uuid.UUID
+}
+
+// TransferFilter selects the transfers of an account, where the account is either the sender or the recipient
+type TransferFilter struct {
+	Account      string
+	Status       *TransferStatus
+	Asset        *string // matches either the source or the destination asset
+	TransferType *TransferType
+	CreatedFrom  *time.Time // inclusive
+	CreatedTo    *time.Time // exclusive
+	After        *TransferCursor
+	Limit        int
+}
+
+func IsValidTransferStatus(status TransferStatus) bool {
+	switch status {
+	case UnsentTransferStatus, SentTransferStatus, CompletedTransferStatus, FailedTransferStatus, CancelledTransferStatus:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsValidTransferType(transferType TransferType) bool {
+	return transferType == InternalTransferType || transferType == ExternalTransferType
 }
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sphere-homework/app/model"
+	"sync"
+)
+
+// ErrUnknownAsset is returned for an asset that is not in the asset table
+var ErrUnknownAsset = errors.New("unknown asset")
+
+// AssetRegistry caches the precision and rounding rules of the assets stored in the asset table
+type AssetRegistry struct {
+	assetRepository AssetRepository
+	mu              sync.RWMutex
+	assets          map[string]model.Asset
+}
+
+func NewAssetRegistry(assetRepository AssetRepository) *AssetRegistry {
+	return &AssetRegistry{
+		assetRepository: assetRepository,
+		assets:          make(map[string]model.Asset),
+	}
+}
+
+// Refresh reloads the assets from the asset table
+func (a *AssetRegistry) Refresh() error {
+	assets, err := a.assetRepository.GetAssets()
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]model.Asset)
+	for _, asset := range assets {
+		loaded[asset.Code] = asset
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.assets = loaded
+
+	return nil
+}
+
+// GetAsset returns the registered asset - an asset missing from the cache, e.g. because another instance created it, is
+// loaded from the asset table. It returns ErrUnknownAsset if there is no such asset.
+func (a *AssetRegistry) GetAsset(code string) (model.Asset, error) {
+	a.mu.RLock()
+	asset, ok := a.assets[code]
+	a.mu.RUnlock()
+
+	if ok {
+		return asset, nil
+	}
+
+	loaded, err := a.assetRepository.GetAsset(code)
+	if err != nil {
+		return model.Asset{}, fmt.Errorf("failed to load asset %s: %w", code, err)
+	}
+
+	if loaded == nil {
+		return model.Asset{}, fmt.Errorf("%w: %s", ErrUnknownAsset, code)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.assets[code] = *loaded
+
+	return *loaded, nil
+}
+
+// Round rounds the amount to the minor unit of the asset
+func (a *AssetRegistry) Round(code string, amount decimal.Decimal) (decimal.Decimal, error) {
+	asset, err := a.GetAsset(code)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return asset.Round(amount), nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sphere-homework/app/model"
+)
+
+// AssetRepository keeps the assets that can be transferred, with their precision and rounding rules
+type AssetRepository interface {
+	InsertAsset(asset model.Asset) (*model.Asset, error)
+	DisableAsset(code string) (*model.Asset, error)
+	GetAsset(code string) (*model.Asset, error)
+	GetAssets() ([]model.Asset, error)
+}
+
+type PostgresAssetRepository struct {
+	db  *pgxpool.Pool
+	ctx context.Context
+}
+
+func NewAssetRepository(db *pgxpool.Pool, ctx context.Context) PostgresAssetRepository {
+	return PostgresAssetRepository{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+func (a *PostgresAssetRepository) InsertAsset(asset model.Asset) (*model.Asset, error) {
+	sql := `
+		INSERT INTO asset (code, minor_units, rounding_mode, enabled)
+		VALUES ($1, $2, $3, TRUE)
+		RETURNING code, minor_units, rounding_mode, enabled, created_at, updated_at`
+
+	var inserted model.Asset
+	err := a.db.QueryRow(a.ctx, sql, asset.Code, asset.MinorUnits, asset.RoundingMode).Scan(
+		&inserted.Code,
+		&inserted.MinorUnits,
+		&inserted.RoundingMode,
+		&inserted.Enabled,
+		&inserted.CreatedAt,
+		&inserted.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inserted, nil
+}
+
+// DisableAsset disables the asset, and returns nil if the asset does not exist
+func (a *PostgresAssetRepository) DisableAsset(code string) (*model.Asset, error) {
+	sql := `
+		UPDATE asset
+		SET enabled = FALSE, updated_at = NOW()
+		WHERE code = $1
+		RETURNING code, minor_units, rounding_mode, enabled, created_at, updated_at`
+
+	var asset model.Asset
+	err := a.db.QueryRow(a.ctx, sql, code).Scan(
+		&asset.Code,
+		&asset.MinorUnits,
+		&asset.RoundingMode,
+		&asset.Enabled,
+		&asset.CreatedAt,
+		&asset.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return &asset, nil
+}
+
+// GetAsset returns the asset, or nil if the asset does not exist
+func (a *PostgresAssetRepository) GetAsset(code string) (*model.Asset, error) {
+	sql := `
+		SELECT code, minor_units, rounding_mode, enabled, created_at, updated_at
+		FROM asset
+		WHERE code = $1`
+
+	var asset model.Asset
+	err := a.db.QueryRow(a.ctx, sql, code).Scan(
+		&asset.Code,
+		&asset.MinorUnits,
+		&asset.RoundingMode,
+		&asset.Enabled,
+		&asset.CreatedAt,
+		&asset.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return &asset, nil
+}
+
+func (a *PostgresAssetRepository) GetAssets() ([]model.Asset, error) {
+	sql := `
+		SELECT code, minor_units, rounding_mode, enabled, created_at, updated_at
+		FROM asset
+		ORDER BY code`
+
+	rows, err := a.db.Query(a.ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []model.Asset
+	for rows.Next() {
+		var asset model.Asset
+		err := rows.Scan(
+			&asset.Code,
+			&asset.MinorUnits,
+			&asset.RoundingMode,
+			&asset.Enabled,
+			&asset.CreatedAt,
+			&asset.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, asset)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return assets, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sphere-homework/app/model"
+	"strings"
+)
+
+// ErrDeadLetterReplayed is returned when replaying a dead letter that was already replayed
+var ErrDeadLetterReplayed = errors.New("dead letter was already replayed")
+
+const deadLetterColumns = `id, created_at, consumer, error, source_topic, source_partition, source_offset, message_key, payload, headers, replayed_at`
+
+// DeadLetterRepository records the dead letters consumed from the dead-letter topic, and marks them replayed
+type DeadLetterRepository interface {
+	InsertDeadLetter(deadLetter model.DeadLetter) error
+	ListDeadLetters(filter model.DeadLetterFilter) ([]model.DeadLetter, error)
+	ReplayDeadLetter(id int64, publish func(model.DeadLetter) error) (*model.DeadLetter, error)
+}
+
+type PostgresDeadLetterRepository struct {
+	db  *pgxpool.Pool
+	ctx context.Context
+}
+
+func NewDeadLetterRepository(db *pgxpool.Pool, ctx context.Context) PostgresDeadLetterRepository {
+	return PostgresDeadLetterRepository{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// InsertDeadLetter records a message consumed from the dead-letter topic - a message consumed again is recorded once
+func (d *PostgresDeadLetterRepository) InsertDeadLetter(deadLetter model.DeadLetter) error {
+	headers, err := json.Marshal(deadLetter.Headers)
+	if err != nil {
+		return err
+	}
+
+	sql := `
+		INSERT INTO dead_letter (consumer, error, source_topic, source_partition, source_offset, message_key, payload, headers)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err = d.db.Exec(d.ctx, sql, deadLetter.Consumer, deadLetter.Error, deadLetter.Topic, deadLetter.Partition,
+		deadLetter.Offset, deadLetter.Key, deadLetter.Payload, string(headers))
+
+	return err
+}
+
+// ListDeadLetters returns the dead letters matching the filter, most recent first
+func (d *PostgresDeadLetterRepository) ListDeadLetters(filter model.DeadLetterFilter) ([]model.DeadLetter, error) {
+	sql := `
+		SELECT ` + deadLetterColumns + ` FROM dead_letter
+		WHERE TRUE`
+
+	var args []any
+	addCondition := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		sql += "\n\t\tAND " + condition
+	}
+
+	if filter.Consumer != nil {
+		addCondition("consumer = ?", *filter.Consumer)
+	}
+
+	if filter.Replayed != nil {
+		if *filter.Replayed {
+			addCondition("replayed_at IS NOT NULL")
+		} else {
+			addCondition("replayed_at IS NULL")
+		}
+	}
+
+	if filter.BeforeId != nil {
+		addCondition("id < ?", *filter.BeforeId)
+	}
+
+	args = append(args, filter.Limit)
+	sql += fmt.Sprintf("\n\t\tORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := d.db.Query(d.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deadLetters []model.DeadLetter
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		deadLetters = append(deadLetters, *deadLetter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetter hands the dead letter to publish and marks it replayed once published - it returns nil if there is no
+// such dead letter, and ErrDeadLetterReplayed if it was already replayed
+func (d *PostgresDeadLetterRepository) ReplayDeadLetter(id int64, publish func(model.DeadLetter) error) (deadLetter *model.DeadLetter, err error) {
+	tx, err := d.db.Begin(d.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || deadLetter == nil {
+			_ = tx.Rollback(d.ctx)
+			return
+		}
+
+		err = tx.Commit(d.ctx)
+	}()
+
+	// the lock keeps concurrent replays of the same dead letter from publishing it twice
+	sql := `SELECT ` + deadLetterColumns + ` FROM dead_letter WHERE id = $1 FOR UPDATE`
+
+	deadLetter, err = scanDeadLetter(tx.QueryRow(d.ctx, sql, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	if deadLetter.ReplayedAt != nil {
+		return nil, ErrDeadLetterReplayed
+	}
+
+	if err = publish(*deadLetter); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(d.ctx, `UPDATE dead_letter SET replayed_at = NOW() WHERE id = $1 RETURNING replayed_at`, id).Scan(&deadLetter.ReplayedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark dead letter replayed: %w", err)
+	}
+
+	return deadLetter, nil
+}
+
+func scanDeadLetter(row pgx.Row) (*model.DeadLetter, error) {
+	var deadLetter model.DeadLetter
+	var headers []byte
+
+	err := row.Scan(
+		&deadLetter.Id,
+		&deadLetter.CreatedAt,
+		&deadLetter.Consumer,
+		&deadLetter.Error,
+		&deadLetter.Topic,
+		&deadLetter.Partition,
+		&deadLetter.Offset,
+		&deadLetter.Key,
+		&deadLetter.Payload,
+		&headers,
+		&deadLetter.ReplayedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(headers, &deadLetter.Headers); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter headers: %w", err)
+	}
+
+	return &deadLetter, nil
+}
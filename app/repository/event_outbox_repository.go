@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"slices"
+	"sphere-homework/app/event"
+	"time"
+)
+
+// EventOutboxRepository relays the events recorded in the event outbox, in the order they were recorded
+type EventOutboxRepository interface {
+	RelayEvents(limit int, lease time.Duration, publish func(event.BaseEvent) error) (int, error)
+}
+
+type PostgresEventOutboxRepository struct {
+	db  *pgxpool.Pool
+	ctx context.Context
+}
+
+func NewEventOutboxRepository(db *pgxpool.Pool, ctx context.Context) PostgresEventOutboxRepository {
+	return PostgresEventOutboxRepository{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// insertOutboxEvents records the events in the outbox as part of the transaction that made the state change they describe
+func insertOutboxEvents(ctx context.Context, tx pgx.Tx, events ...*event.BaseEvent) error {
+	sql := `
+		INSERT INTO event_outbox (created_at, event_type, sender, event)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	for _, e := range events {
+		if _, err := tx.Exec(ctx, sql, time.UnixMilli(e.Timestamp), e.EventType, e.Sender, string(e.Payload)); err != nil {
+			return fmt.Errorf("failed to insert outbox event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// eventRelayLockKey is the advisory lock relays take to claim events one at a time
+const eventRelayLockKey = "event_outbox_relay"
+
+// RelayEvents claims the oldest unpublished events for the lease, hands them to publish in order, and marks those
+// published - it stops at the first event that fails to publish so the order of the events is kept, which publish is
+// expected to report itself. No events are claimed while another relay holds a claim, and events are only handed to
+// publish during the first half of the lease, so publish must return within half of the lease for the claim not to
+// expire while an event is being published. Events claimed by a relay that died are claimed again once the lease expired.
+func (e *PostgresEventOutboxRepository) RelayEvents(limit int, lease time.Duration, publish func(event.BaseEvent) error) (int, error) {
+	claimId := uuid.New()
+	deadline := time.Now().Add(lease / 2)
+
+	ids, events, err := e.claimEvents(claimId, limit, lease)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	// the events published so far are marked published, the one that failed is retried on the next run
+	published := 0
+	for _, outboxEvent := range events {
+		if time.Now().After(deadline) || publish(outboxEvent) != nil {
+			break
+		}
+		published++
+	}
+
+	if err = e.releaseEvents(claimId, ids[:published]); err != nil {
+		return 0, err
+	}
+
+	return published, nil
+}
+
+// claimEvents claims up to limit of the oldest unpublished events, unless another relay holds a claim
+func (e *PostgresEventOutboxRepository) claimEvents(claimId uuid.UUID, limit int, lease time.Duration) (ids []int64, events []event.BaseEvent, err error) {
+	tx, err := e.db.Begin(e.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(e.ctx)
+			return
+		}
+
+		err = tx.Commit(e.ctx)
+	}()
+
+	// serializes the relays claiming events, the lock is released when the claim is committed
+	var locked bool
+	if err = tx.QueryRow(e.ctx, `SELECT pg_try_advisory_xact_lock(hashtext($1))`, eventRelayLockKey).Scan(&locked); err != nil || !locked {
+		return nil, nil, err
+	}
+
+	var claimed bool
+	sql := `SELECT EXISTS (SELECT 1 FROM event_outbox WHERE published_at IS NULL AND claimed_until > NOW())`
+	if err = tx.QueryRow(e.ctx, sql).Scan(&claimed); err != nil || claimed {
+		return nil, nil, err
+	}
+
+	sql = `
+		UPDATE event_outbox
+		SET claim_id = $1, claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE published_at IS NULL
+			ORDER BY id
+			LIMIT $3
+		)
+		RETURNING id, created_at, event_type, sender, event
+	`
+
+	rows, err := tx.Query(e.ctx, sql, claimId, lease.Seconds(), limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	type outboxRow struct {
+		id    int64
+		event event.BaseEvent
+	}
+
+	var claimedRows []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		var timestamp time.Time
+		if err = rows.Scan(&row.id, &timestamp, &row.event.EventType, &row.event.Sender, &row.event.Payload); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row.event.Timestamp = timestamp.UnixMilli()
+		claimedRows = append(claimedRows, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	slices.SortFunc(claimedRows, func(a, b outboxRow) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	for _, row := range claimedRows {
+		ids = append(ids, row.id)
+		events = append(events, row.event)
+	}
+
+	return ids, events, nil
+}
+
+// releaseEvents marks the published events published, and releases the claim on the others
+func (e *PostgresEventOutboxRepository) releaseEvents(claimId uuid.UUID, published []int64) (err error) {
+	tx, err := e.db.Begin(e.ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(e.ctx)
+			return
+		}
+
+		err = tx.Commit(e.ctx)
+	}()
+
+	if len(published) > 0 {
+		// events claimed by another relay once this claim expired are left to that relay, which publishes them again
+		sql := `UPDATE event_outbox SET published_at = NOW(), claim_id = NULL, claimed_until = NULL WHERE id = ANY($1) AND claim_id = $2`
+		if _, err = tx.Exec(e.ctx, sql, published, claimId); err != nil {
+			return fmt.Errorf("failed to mark outbox events published: %w", err)
+		}
+	}
+
+	sql := `UPDATE event_outbox SET claim_id = NULL, claimed_until = NULL WHERE claim_id = $1`
+	if _, err = tx.Exec(e.ctx, sql, claimId); err != nil {
+		return fmt.Errorf("failed to release outbox events: %w", err)
+	}
+
+	return nil
+}
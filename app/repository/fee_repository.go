@@ -3,32 +3,37 @@ package repository
 import (
 	"context"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 )
 
-type FeeRepository struct {
+type FeeRepository interface {
+	GetFee(toAsset string) (decimal.Decimal, error)
+}
+
+type PostgresFeeRepository struct {
 	db  *pgxpool.Pool
 	ctx context.Context
 }
 
-func NewFeeRepository(db *pgxpool.Pool, ctx context.Context) FeeRepository {
-	return FeeRepository{
+func NewFeeRepository(db *pgxpool.Pool, ctx context.Context) PostgresFeeRepository {
+	return PostgresFeeRepository{
 		db:  db,
 		ctx: ctx,
 	}
 }
 
-func (f *FeeRepository) GetFee(toAsset string) (float64, error) {
+func (f *PostgresFeeRepository) GetFee(toAsset string) (decimal.Decimal, error) {
 	query := `
 		SELECT fee 
 		FROM fee
 		WHERE to_asset = $1
 	`
 
-	var fee float64
+	var fee decimal.Decimal
 
 	err := f.db.QueryRow(f.ctx, query, toAsset).Scan(&fee)
 	if err != nil {
-		return 0, err
+		return decimal.Zero, err
 	}
 
 	return fee, nil
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"sphere-homework/app/model"
+)
+
+// getActiveHold locks and returns the transfer's hold if it is still held, or nil otherwise
+func getActiveHold(ctx context.Context, tx pgx.Tx, transferId uuid.UUID) (*model.Hold, error) {
+	query := `
+		SELECT transfer_id, account, asset, amount, status, created_at, updated_at
+		FROM hold
+		WHERE transfer_id = $1
+		AND status = $2
+		FOR UPDATE
+	`
+
+	var hold model.Hold
+	err := tx.QueryRow(ctx, query, transferId, model.HeldHoldStatus).Scan(
+		&hold.TransferId,
+		&hold.Account,
+		&hold.Asset,
+		&hold.Amount,
+		&hold.Status,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return &hold, nil
+}
+
+// updateHold moves an active hold to captured or released, and removes its amount from the account's held funds
+func updateHold(ctx context.Context, tx pgx.Tx, hold model.Hold, status model.HoldStatus) error {
+	query := `UPDATE hold SET status = $2, updated_at = NOW() WHERE transfer_id = $1`
+	if _, err := tx.Exec(ctx, query, hold.TransferId, status); err != nil {
+		return fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	query = `UPDATE ledger SET held = held - $1 WHERE account_name = $2 AND asset = $3`
+	if _, err := tx.Exec(ctx, query, hold.Amount, hold.Account, hold.Asset); err != nil {
+		return fmt.Errorf("failed to update held balance: %w", err)
+	}
+
+	return nil
+}
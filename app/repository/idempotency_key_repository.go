@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sphere-homework/app/model"
+)
+
+// IdempotencyKeyRepository keeps the idempotency keys of the senders' transfer requests, with the response to replay
+type IdempotencyKeyRepository interface {
+	GetIdempotencyKey(sender string, key string) (*model.IdempotencyKey, error)
+	ReserveIdempotencyKey(key model.IdempotencyKey) (bool, error)
+	SetIdempotencyKeyStatusCode(sender string, key string, statusCode int) error
+	DeleteIdempotencyKey(sender string, key string) error
+}
+
+type PostgresIdempotencyKeyRepository struct {
+	db  *pgxpool.Pool
+	ctx context.Context
+}
+
+func NewIdempotencyKeyRepository(db *pgxpool.Pool, ctx context.Context) PostgresIdempotencyKeyRepository {
+	return PostgresIdempotencyKeyRepository{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// GetIdempotencyKey returns the sender's key, or nil if the key does not exist or has expired
+func (i *PostgresIdempotencyKeyRepository) GetIdempotencyKey(sender string, key string) (*model.IdempotencyKey, error) {
+	sql := `
+		SELECT idempotency_key, sender, request_hash, transfer_id, response, status_code, created_at, expires_at
+		FROM idempotency_key
+		WHERE sender = $1
+		AND idempotency_key = $2
+		AND expires_at > NOW()`
+
+	var idempotencyKey model.IdempotencyKey
+	err := i.db.QueryRow(i.ctx, sql, sender, key).Scan(
+		&idempotencyKey.Key,
+		&idempotencyKey.Sender,
+		&idempotencyKey.RequestHash,
+		&idempotencyKey.TransferId,
+		&idempotencyKey.Response,
+		&idempotencyKey.StatusCode,
+		&idempotencyKey.CreatedAt,
+		&idempotencyKey.ExpiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return &idempotencyKey, nil
+}
+
+// ReserveIdempotencyKey stores the sender's key if it does not exist yet or has expired - it returns false if the key
+// is already taken. A key reserved again once it expired is taken off the outbox row of the transfer it was reserved for
+// before, so the outbox does not drop the new transfer as a duplicate.
+func (i *PostgresIdempotencyKeyRepository) ReserveIdempotencyKey(key model.IdempotencyKey) (reserved bool, err error) {
+	tx, err := i.db.Begin(i.ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil || !reserved {
+			_ = tx.Rollback(i.ctx)
+			return
+		}
+
+		err = tx.Commit(i.ctx)
+	}()
+
+	sql := `
+		INSERT INTO idempotency_key (idempotency_key, sender, request_hash, transfer_id, response, status_code, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sender, idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			transfer_id = EXCLUDED.transfer_id,
+			response = EXCLUDED.response,
+			status_code = EXCLUDED.status_code,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at <= NOW()`
+
+	tag, err := tx.Exec(i.ctx, sql, key.Key, key.Sender, key.RequestHash, key.TransferId, string(key.Response), key.StatusCode, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	sql = `
+		UPDATE outgoing_transfer
+		SET idempotency_key = NULL
+		WHERE sender = $1
+		AND idempotency_key = $2
+		AND transfer_id <> $3`
+
+	if _, err = tx.Exec(i.ctx, sql, key.Sender, key.Key, key.TransferId); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetIdempotencyKeyStatusCode updates the status code replayed for the sender's key
+func (i *PostgresIdempotencyKeyRepository) SetIdempotencyKeyStatusCode(sender string, key string, statusCode int) error {
+	sql := `
+		UPDATE idempotency_key
+		SET status_code = $3
+		WHERE sender = $1
+		AND idempotency_key = $2`
+
+	_, err := i.db.Exec(i.ctx, sql, sender, key, statusCode)
+
+	return err
+}
+
+// DeleteIdempotencyKey releases a key reserved by a request that could not be completed
+func (i *PostgresIdempotencyKeyRepository) DeleteIdempotencyKey(sender string, key string) error {
+	sql := `
+		DELETE FROM idempotency_key
+		WHERE sender = $1
+		AND idempotency_key = $2`
+
+	_, err := i.db.Exec(i.ctx, sql, sender, key)
+
+	return err
+}
@@ -2,30 +2,67 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
+	"sphere-homework/app/event"
 	"sphere-homework/app/model"
+	"time"
 )
 
 const SystemAccount = "system"
 
-type LedgerRepository struct {
-	db     *pgxpool.Pool
-	ctx    context.Context
-	logger *zap.Logger
+var ErrInsufficientBalance = errors.New("not enough available balance")
+
+// RoundingAccount collects the remainders of rounding sent amounts to the destination asset's minor unit
+const RoundingAccount = "system_rounding"
+
+// LedgerRepository keeps the balances and held funds of the accounts, and applies transfers to them
+type LedgerRepository interface {
+	InsertNewEntryIfNotExists(asset string, accountName string) error
+	GetAccountBalances(account string) ([]model.AccountBalance, error)
+	GetAccountBalance(account string, asset string) (*model.AccountBalance, error)
+	GetBalances(account string) ([]model.LedgerBalance, error)
+	Transfer(transfer *model.Transfer) error
+	GetStatement(account string, from time.Time, to time.Time, asset *string) (*model.Statement, error)
+	PlaceHold(hold model.Hold) error
+	ReleaseHold(transferId uuid.UUID) (bool, error)
+	ReleaseExpiredHolds(expiry time.Duration, limit int) ([]model.Hold, error)
 }
 
-func NewLedgerRepository(db *pgxpool.Pool, ctx context.Context, logger *zap.Logger) LedgerRepository {
-	return LedgerRepository{
-		db:     db,
-		ctx:    ctx,
-		logger: logger,
+// ledgerKey identifies the ledger entry of an account's asset
+type ledgerKey struct {
+	account string
+	asset   string
+}
+
+// ledgerRow is the balance and the held funds of a ledger entry
+type ledgerRow struct {
+	balance decimal.Decimal
+	held    decimal.Decimal
+}
+
+type PostgresLedgerRepository struct {
+	db            *pgxpool.Pool
+	ctx           context.Context
+	logger        *zap.Logger
+	assetRegistry *AssetRegistry
+}
+
+func NewLedgerRepository(db *pgxpool.Pool, ctx context.Context, logger *zap.Logger, assetRegistry *AssetRegistry) PostgresLedgerRepository {
+	return PostgresLedgerRepository{
+		db:            db,
+		ctx:           ctx,
+		logger:        logger,
+		assetRegistry: assetRegistry,
 	}
 }
 
-func (l *LedgerRepository) InsertNewEntryIfNotExists(asset string, accountName string) error {
+func (l *PostgresLedgerRepository) InsertNewEntryIfNotExists(asset string, accountName string) error {
 	query := `
 		INSERT INTO ledger(asset, account_name) 
 		VALUES ($1, $2)
@@ -40,7 +77,66 @@ func (l *LedgerRepository) InsertNewEntryIfNotExists(asset string, accountName s
 	return nil
 }
 
-func (l *LedgerRepository) GetBalances(account string) ([]model.LedgerBalance, error) {
+// GetAccountBalances returns the balance, pending outgoing and available amount of each of the account's assets
+func (l *PostgresLedgerRepository) GetAccountBalances(account string) ([]model.AccountBalance, error) {
+	return l.getAccountBalances(account, nil)
+}
+
+// GetAccountBalance returns the balance of a single asset of the account, or nil if the account has no ledger entry for the asset
+func (l *PostgresLedgerRepository) GetAccountBalance(account string, asset string) (*model.AccountBalance, error) {
+	balances, err := l.getAccountBalances(account, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(balances) == 0 {
+		return nil, nil
+	}
+
+	return &balances[0], nil
+}
+
+func (l *PostgresLedgerRepository) getAccountBalances(account string, asset *string) ([]model.AccountBalance, error) {
+	query := `
+		SELECT l.asset, l.balance, l.held, COALESCE(p.pending, 0)
+		FROM ledger l
+		LEFT JOIN (
+			SELECT from_asset, SUM(requested_amount) AS pending
+			FROM outgoing_transfer
+			WHERE sender = $1
+			AND status = $2
+			GROUP BY from_asset
+		) p ON p.from_asset = l.asset
+		WHERE l.account_name = $1
+		AND ($3::VARCHAR IS NULL OR l.asset = $3)
+		ORDER BY l.asset
+	`
+
+	rows, err := l.db.Query(l.ctx, query, account, model.UnsentTransferStatus, asset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []model.AccountBalance
+	for rows.Next() {
+		var balance model.AccountBalance
+		if err := rows.Scan(&balance.Asset, &balance.Balance, &balance.Held, &balance.PendingOutgoing); err != nil {
+			return nil, err
+		}
+
+		balance.Available = balance.Balance.Sub(balance.Held)
+		balances = append(balances, balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+func (l *PostgresLedgerRepository) GetBalances(account string) ([]model.LedgerBalance, error) {
 	// Get the balance
 	query := `
 		SELECT asset, balance 
@@ -54,10 +150,10 @@ func (l *LedgerRepository) GetBalances(account string) ([]model.LedgerBalance, e
 	}
 
 	defer balanceRows.Close()
-	balances := make(map[string]float64)
+	balances := make(map[string]decimal.Decimal)
 	for balanceRows.Next() {
 		var asset string
-		var balance float64
+		var balance decimal.Decimal
 		if err := balanceRows.Scan(&asset, &balance); err != nil {
 			return nil, err
 		}
@@ -84,8 +180,8 @@ func (l *LedgerRepository) GetBalances(account string) ([]model.LedgerBalance, e
 	flows := make(map[string]model.LedgerBalance)
 	for flowRows.Next() {
 		var asset string
-		var inflow float64
-		var outflow float64
+		var inflow decimal.Decimal
+		var outflow decimal.Decimal
 		if err := flowRows.Scan(&asset, &inflow, &outflow); err != nil {
 			return nil, err
 		}
@@ -111,8 +207,8 @@ func (l *LedgerRepository) GetBalances(account string) ([]model.LedgerBalance, e
 			result = append(result, model.LedgerBalance{
 				Asset:   key,
 				Amount:  balance,
-				Inflow:  0,
-				Outflow: 0,
+				Inflow:  decimal.Zero,
+				Outflow: decimal.Zero,
 			})
 		}
 	}
@@ -120,74 +216,125 @@ func (l *LedgerRepository) GetBalances(account string) ([]model.LedgerBalance, e
 	return result, nil
 }
 
-func (l *LedgerRepository) Transfer(transfer *model.Transfer) error {
+// Transfer applies the locked transfer to the ledger, capturing the funds held for it if any, and stores the transfer
+// with the status, sent and settled times set by the caller and the sent amount, unlocked
+func (l *PostgresLedgerRepository) Transfer(transfer *model.Transfer) (err error) {
+	// the target asset is looked up before any ledger entry is locked, as it may have to be loaded from the asset table
+	toAsset, err := l.assetRegistry.GetAsset(transfer.ToAsset)
+	if err != nil {
+		return err
+	}
+
 	tx, err := l.db.Begin(l.ctx)
 	if err != nil {
 		return err
 	}
 
 	defer func() {
-		var err error
+		var txErr error
 		if p := recover(); p != nil {
-			err = tx.Rollback(l.ctx)
+			txErr = tx.Rollback(l.ctx)
+			err = fmt.Errorf("transfer panicked: %v", p)
 		} else if err != nil {
-			err = tx.Rollback(l.ctx)
+			txErr = tx.Rollback(l.ctx)
 		} else {
-			err = tx.Commit(l.ctx)
+			txErr = tx.Commit(l.ctx)
+			err = txErr
 		}
 
-		if err != nil {
-			l.logger.Error("failed to commit / rollback transaction", zap.Error(err))
+		if txErr != nil {
+			l.logger.Error("failed to commit / rollback transaction", zap.Error(txErr))
 		}
 	}()
 
-	var sourceBalance float64
-	var destBalance float64
+	accounts := []ledgerKey{
+		{account: transfer.Sender, asset: transfer.FromAsset},
+		{account: transfer.Recipient, asset: transfer.ToAsset},
+		// the rounding remainder of the sent amount is booked to the rounding account
+		{account: RoundingAccount, asset: transfer.ToAsset},
+	}
 
-	query := `SELECT balance FROM ledger WHERE account_name = $1 AND asset = $2 FOR UPDATE`
+	// fees are credited to the system account
+	if transfer.Sender != SystemAccount {
+		accounts = append(accounts, ledgerKey{account: SystemAccount, asset: transfer.FromAsset})
+	}
 
-	// Lock both sender and receiver entries of the ledger via SELECT FOR UPDATE
-	err = tx.QueryRow(l.ctx, query, transfer.Sender, transfer.FromAsset).Scan(&sourceBalance)
+	ledger, err := lockLedgerEntries(l.ctx, tx, accounts)
 	if err != nil {
 		return err
 	}
 
-	err = tx.QueryRow(l.ctx, query, transfer.Recipient, transfer.ToAsset).Scan(&destBalance)
+	source := ledger[accounts[0]]
+
+	// funds held for this transfer when it was accepted are part of what the sender can spend on it
+	hold, err := getActiveHold(l.ctx, tx, transfer.TransferId)
 	if err != nil {
 		return err
 	}
 
-	// Also lock system account because we need to transfer fees to system account
-	if transfer.Sender != SystemAccount {
-		var ledgerBalance float64
-		err = tx.QueryRow(l.ctx, query, SystemAccount, transfer.FromAsset).Scan(&ledgerBalance)
-		if err != nil {
+	var heldForTransfer = decimal.Zero
+	if hold != nil {
+		heldForTransfer = hold.Amount
+	}
+
+	if source.balance.Sub(source.held).Add(heldForTransfer).LessThan(transfer.RequestedAmount) {
+		return ErrInsufficientBalance
+	}
+
+	if hold != nil {
+		if err = updateHold(l.ctx, tx, *hold, model.CapturedHoldStatus); err != nil {
 			return err
 		}
 	}
 
+	entries, sendAmount := transferLedgerEntries(toAsset, *transfer)
+
+	// apply the ledger operations
+	if err = applyLedgerEntries(l.ctx, tx, entries); err != nil {
+		l.logger.Error("failed to apply ledger entries", zap.Error(err))
+		return err
+	}
+
+	transfer.SentAmount = &sendAmount
+
+	// the outcome of the transfer and its event are committed together with the ledger entries, so a crash cannot leave
+	// the ledger updated but the transfer unsent
+	if _, err = unlockAndUpdateTransfer(l.ctx, tx, *transfer); err != nil {
+		return err
+	}
+
+	sentEvent, err := event.NewTransferSent(*transfer)
+	if err != nil {
+		return err
+	}
+
+	return insertOutboxEvents(l.ctx, tx, sentEvent)
+}
+
+// transferLedgerEntries returns the ledger entries that apply the transfer, and the amount sent to the recipient rounded
+// to the minor unit of the target asset
+func transferLedgerEntries(toAsset model.Asset, transfer model.Transfer) ([]model.LedgerEntry, decimal.Decimal) {
 	var entries []model.LedgerEntry
 
 	// deduct amount is simply the requested amount
 	var deductAmount = transfer.RequestedAmount
 
-	// send amount is requested amount less fees, converted to the target asset
-	var sendAmount = (transfer.RequestedAmount - transfer.Fee) * transfer.Rate
-
-	if sourceBalance < deductAmount {
-		return fmt.Errorf("not enough balance for transfer")
-	}
+	// send amount is requested amount less fees, converted to the target asset and rounded to its minor unit -
+	// the difference between the converted and the rounded amount is booked to the rounding account
+	var convertedAmount = model.RoundToStorage(transfer.RequestedAmount.Sub(transfer.Fee).Mul(transfer.Rate))
+	var sendAmount = toAsset.Round(convertedAmount)
+	var roundingRemainder = convertedAmount.Sub(sendAmount)
 
 	// Debit deduct amount from sender
 	entries = append(entries, model.LedgerEntry{
 		TransferId: transfer.TransferId,
 		Account:    transfer.Sender,
 		Asset:      transfer.FromAsset,
-		Amount:     -deductAmount,
+		Amount:     deductAmount.Neg(),
 		Type:       model.TransferLedgerEntryType,
 	})
 
-	if transfer.Fee > 0 {
+	if transfer.Fee.IsPositive() {
 		// no fees for internal transfers
 		if transfer.Sender != SystemAccount || transfer.Recipient != SystemAccount {
 			// Credit fees to system account
@@ -213,18 +360,69 @@ func (l *LedgerRepository) Transfer(transfer *model.Transfer) error {
 		Type:       model.TransferLedgerEntryType,
 	})
 
-	// apply the ledger operations
-	if err = l.applyLedgerEntries(entries, &tx); err != nil {
-		l.logger.Error("failed to apply ledger entries", zap.Error(err))
-		return err
+	if !roundingRemainder.IsZero() {
+		entries = append(entries, model.LedgerEntry{
+			TransferId: transfer.TransferId,
+			Account:    RoundingAccount,
+			Asset:      transfer.ToAsset,
+			Amount:     roundingRemainder,
+			Type:       model.RoundingLedgerEntryType,
+		})
 	}
 
-	transfer.SentAmount = &sendAmount
+	return entries, sendAmount
+}
 
-	return nil
+// lockLedgerEntries locks the ledger entries of the accounts in a fixed order, and returns their balances - transactions
+// locking several ledger entries go through here, so concurrent transfers and reversals cannot deadlock on them. It
+// returns pgx.ErrNoRows if an account has no ledger entry.
+func lockLedgerEntries(ctx context.Context, tx pgx.Tx, accounts []ledgerKey) (map[ledgerKey]ledgerRow, error) {
+	accountNames := make([]string, len(accounts))
+	assets := make([]string, len(accounts))
+	for i, key := range accounts {
+		accountNames[i] = key.account
+		assets[i] = key.asset
+	}
+
+	query := `
+		SELECT account_name, asset, balance, held FROM ledger
+		WHERE (account_name, asset) IN (SELECT * FROM unnest($1::varchar[], $2::varchar[]))
+		ORDER BY account_name, asset
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, query, accountNames, assets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	ledger := make(map[ledgerKey]ledgerRow)
+	for rows.Next() {
+		var key ledgerKey
+		var row ledgerRow
+		if err := rows.Scan(&key.account, &key.asset, &row.balance, &row.held); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		ledger[key] = row
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	for _, key := range accounts {
+		if _, ok := ledger[key]; !ok {
+			return nil, pgx.ErrNoRows
+		}
+	}
+
+	return ledger, nil
 }
 
-func (l *LedgerRepository) applyLedgerEntries(entries []model.LedgerEntry, tx *pgx.Tx) error {
+// applyLedgerEntries debits or credits each entry against the ledger and records it in the ledger history
+func applyLedgerEntries(ctx context.Context, tx pgx.Tx, entries []model.LedgerEntry) error {
 	query := `UPDATE ledger SET balance = balance + $1 WHERE account_name = $2 AND asset = $3`
 	queryHistory := `
 		INSERT INTO ledger_history (transfer_id, account, asset, amount, ledger_entry_type) 
@@ -232,15 +430,244 @@ func (l *LedgerRepository) applyLedgerEntries(entries []model.LedgerEntry, tx *p
 
 	for _, entry := range entries {
 		// debits or credits entry against the ledger
-		if _, err := (*tx).Exec(l.ctx, query, entry.Amount, entry.Account, entry.Asset); err != nil {
+		if _, err := tx.Exec(ctx, query, entry.Amount, entry.Account, entry.Asset); err != nil {
 			return fmt.Errorf("failed to apply ledger entry: %w", err)
 		}
 
 		// record ledger history
-		if _, err := (*tx).Exec(l.ctx, queryHistory, entry.TransferId, entry.Account, entry.Asset, entry.Amount, entry.Type); err != nil {
+		if _, err := tx.Exec(ctx, queryHistory, entry.TransferId, entry.Account, entry.Asset, entry.Amount, entry.Type); err != nil {
 			return fmt.Errorf("failed to apply ledger history entry: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// GetStatement returns the ledger entries of the account in [from, to) with opening and closing balances per asset -
+// opening balances are derived from the current balance, since the initial balances have no ledger history
+func (l *PostgresLedgerRepository) GetStatement(account string, from time.Time, to time.Time, asset *string) (*model.Statement, error) {
+	// read balances and history from the same snapshot
+	tx, err := l.db.BeginTx(l.ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(l.ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			l.logger.Error("failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	query := `
+		SELECT l.asset, l.balance - COALESCE(SUM(h.amount), 0)
+		FROM ledger l
+		LEFT JOIN ledger_history h ON h.account = l.account_name AND h.asset = l.asset AND h.created_at >= $2
+		WHERE l.account_name = $1
+		AND ($3::VARCHAR IS NULL OR l.asset = $3)
+		GROUP BY l.asset, l.balance
+		ORDER BY l.asset
+	`
+
+	openingRows, err := tx.Query(l.ctx, query, account, from, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []string
+	openingBalances := make(map[string]decimal.Decimal)
+	for openingRows.Next() {
+		var asset string
+		var openingBalance decimal.Decimal
+		if err := openingRows.Scan(&asset, &openingBalance); err != nil {
+			openingRows.Close()
+			return nil, err
+		}
+
+		assets = append(assets, asset)
+		openingBalances[asset] = openingBalance
+	}
+	openingRows.Close()
+
+	if err := openingRows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT created_at, transfer_id, ledger_entry_type, asset, amount
+		FROM ledger_history
+		WHERE account = $1
+		AND created_at >= $2
+		AND created_at < $3
+		AND ($4::VARCHAR IS NULL OR asset = $4)
+		ORDER BY created_at, transfer_id, ledger_entry_type
+	`
+
+	entryRows, err := tx.Query(l.ctx, query, account, from, to, asset)
+	if err != nil {
+		return nil, err
+	}
+	defer entryRows.Close()
+
+	entries := make(map[string][]model.StatementEntry)
+	for entryRows.Next() {
+		var entry model.StatementEntry
+		if err := entryRows.Scan(&entry.CreatedAt, &entry.TransferId, &entry.Type, &entry.Asset, &entry.Amount); err != nil {
+			return nil, err
+		}
+
+		entries[entry.Asset] = append(entries[entry.Asset], entry)
+	}
+
+	if err := entryRows.Err(); err != nil {
+		return nil, err
+	}
+
+	statement := model.Statement{
+		Account: account,
+		From:    from,
+		To:      to,
+		Assets:  []model.AssetStatement{},
+	}
+
+	for _, asset := range assets {
+		statement.Assets = append(statement.Assets, model.NewAssetStatement(asset, openingBalances[asset], entries[asset]))
+	}
+
+	return &statement, nil
+}
+
+// PlaceHold reserves the hold's amount against the account's available balance, it returns ErrInsufficientBalance if
+// the account has not enough available balance
+func (l *PostgresLedgerRepository) PlaceHold(hold model.Hold) (err error) {
+	tx, err := l.db.Begin(l.ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if txErr := tx.Rollback(l.ctx); txErr != nil {
+				l.logger.Error("failed to rollback transaction", zap.Error(txErr))
+			}
+			return
+		}
+
+		err = tx.Commit(l.ctx)
+	}()
+
+	query := `
+		UPDATE ledger
+		SET held = held + $1
+		WHERE account_name = $2
+		AND asset = $3
+		AND balance - held >= $1
+	`
+
+	tag, err := tx.Exec(l.ctx, query, hold.Amount, hold.Account, hold.Asset)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrInsufficientBalance
+	}
+
+	query = `
+		INSERT INTO hold (transfer_id, account, asset, amount, status)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = tx.Exec(l.ctx, query, hold.TransferId, hold.Account, hold.Asset, hold.Amount, model.HeldHoldStatus)
+
+	return err
+}
+
+// ReleaseHold makes the funds held for the transfer available again - it returns false if the transfer has no active hold
+func (l *PostgresLedgerRepository) ReleaseHold(transferId uuid.UUID) (released bool, err error) {
+	tx, err := l.db.Begin(l.ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			if txErr := tx.Rollback(l.ctx); txErr != nil {
+				l.logger.Error("failed to rollback transaction", zap.Error(txErr))
+			}
+			return
+		}
+
+		err = tx.Commit(l.ctx)
+	}()
+
+	hold, err := getActiveHold(l.ctx, tx, transferId)
+	if err != nil || hold == nil {
+		return false, err
+	}
+
+	if err = updateHold(l.ctx, tx, *hold, model.ReleasedHoldStatus); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseExpiredHolds releases up to limit holds placed more than expiry ago for transfers that never reached the
+// transfer outbox, e.g. because their created event was lost after its delivery could not be confirmed - it returns the
+// released holds
+func (l *PostgresLedgerRepository) ReleaseExpiredHolds(expiry time.Duration, limit int) (released []model.Hold, err error) {
+	tx, err := l.db.Begin(l.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			if txErr := tx.Rollback(l.ctx); txErr != nil {
+				l.logger.Error("failed to rollback transaction", zap.Error(txErr))
+			}
+			return
+		}
+
+		err = tx.Commit(l.ctx)
+	}()
+
+	query := `
+		SELECT transfer_id, account, asset, amount, status, created_at, updated_at
+		FROM hold
+		WHERE status = $1
+		AND created_at < NOW() - make_interval(secs => $2)
+		AND NOT EXISTS (SELECT 1 FROM outgoing_transfer WHERE outgoing_transfer.transfer_id = hold.transfer_id)
+		ORDER BY created_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := tx.Query(l.ctx, query, model.HeldHoldStatus, expiry.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var holds []model.Hold
+	for rows.Next() {
+		var hold model.Hold
+		if err = rows.Scan(&hold.TransferId, &hold.Account, &hold.Asset, &hold.Amount, &hold.Status, &hold.CreatedAt, &hold.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		holds = append(holds, hold)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	for _, hold := range holds {
+		if err = updateHold(l.ctx, tx, hold, model.ReleasedHoldStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	return holds, nil
+}
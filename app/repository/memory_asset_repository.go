@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"sphere-homework/app/model"
+	"strings"
+	"time"
+)
+
+// MemoryAssetRepository is the AssetRepository of a MemoryStore
+type MemoryAssetRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryAssetRepository(store *MemoryStore) *MemoryAssetRepository {
+	return &MemoryAssetRepository{
+		store: store,
+	}
+}
+
+func (a *MemoryAssetRepository) InsertAsset(asset model.Asset) (inserted *model.Asset, err error) {
+	err = a.store.transaction(func(now time.Time) error {
+		if _, ok := a.store.state.assets[asset.Code]; ok {
+			return fmt.Errorf("asset %s already exists", asset.Code)
+		}
+
+		asset.Enabled = true
+		asset.CreatedAt = now
+		asset.UpdatedAt = now
+		a.store.state.assets[asset.Code] = asset
+		inserted = &asset
+
+		return nil
+	})
+
+	return inserted, err
+}
+
+// DisableAsset disables the asset, and returns nil if the asset does not exist
+func (a *MemoryAssetRepository) DisableAsset(code string) (disabled *model.Asset, err error) {
+	err = a.store.transaction(func(now time.Time) error {
+		asset, ok := a.store.state.assets[code]
+		if !ok {
+			return nil
+		}
+
+		asset.Enabled = false
+		asset.UpdatedAt = now
+		a.store.state.assets[code] = asset
+		disabled = &asset
+
+		return nil
+	})
+
+	return disabled, err
+}
+
+// GetAsset returns the asset, or nil if the asset does not exist
+func (a *MemoryAssetRepository) GetAsset(code string) (*model.Asset, error) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	asset, ok := a.store.state.assets[code]
+	if !ok {
+		return nil, nil
+	}
+
+	return &asset, nil
+}
+
+func (a *MemoryAssetRepository) GetAssets() ([]model.Asset, error) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	var assets []model.Asset
+	for _, asset := range a.store.state.assets {
+		assets = append(assets, asset)
+	}
+
+	slices.SortFunc(assets, func(a, b model.Asset) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+
+	return assets, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"sphere-homework/app/model"
+	"time"
+)
+
+// MemoryDeadLetterRepository is the DeadLetterRepository of a MemoryStore
+type MemoryDeadLetterRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryDeadLetterRepository(store *MemoryStore) *MemoryDeadLetterRepository {
+	return &MemoryDeadLetterRepository{
+		store: store,
+	}
+}
+
+// InsertDeadLetter records a message consumed from the dead-letter topic - a message consumed again is recorded once
+func (d *MemoryDeadLetterRepository) InsertDeadLetter(deadLetter model.DeadLetter) error {
+	return d.store.transaction(func(now time.Time) error {
+		for _, existing := range d.store.state.deadLetters {
+			if existing.Consumer == deadLetter.Consumer && existing.Topic == deadLetter.Topic &&
+				existing.Partition == deadLetter.Partition && existing.Offset == deadLetter.Offset {
+				return nil
+			}
+		}
+
+		deadLetter.Id = int64(len(d.store.state.deadLetters) + 1)
+		deadLetter.CreatedAt = now
+		deadLetter.ReplayedAt = nil
+		d.store.state.deadLetters = append(d.store.state.deadLetters, deadLetter)
+
+		return nil
+	})
+}
+
+// ListDeadLetters returns the dead letters matching the filter, most recent first
+func (d *MemoryDeadLetterRepository) ListDeadLetters(filter model.DeadLetterFilter) ([]model.DeadLetter, error) {
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+
+	var deadLetters []model.DeadLetter
+	for i := len(d.store.state.deadLetters) - 1; i >= 0; i-- {
+		deadLetter := d.store.state.deadLetters[i]
+		if len(deadLetters) == filter.Limit {
+			break
+		}
+
+		if filter.Consumer != nil && deadLetter.Consumer != *filter.Consumer {
+			continue
+		}
+
+		if filter.Replayed != nil && *filter.Replayed != (deadLetter.ReplayedAt != nil) {
+			continue
+		}
+
+		if filter.BeforeId != nil && deadLetter.Id >= *filter.BeforeId {
+			continue
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetter hands the dead letter to publish and marks it replayed once published - it returns nil if there is no
+// such dead letter, and ErrDeadLetterReplayed if it was already replayed
+func (d *MemoryDeadLetterRepository) ReplayDeadLetter(id int64, publish func(model.DeadLetter) error) (deadLetter *model.DeadLetter, err error) {
+	err = d.store.transaction(func(now time.Time) error {
+		if id < 1 || id > int64(len(d.store.state.deadLetters)) {
+			return nil
+		}
+
+		replayed := d.store.state.deadLetters[id-1]
+		if replayed.ReplayedAt != nil {
+			return ErrDeadLetterReplayed
+		}
+
+		if err := publish(replayed); err != nil {
+			return err
+		}
+
+		replayed.ReplayedAt = &now
+		d.store.state.deadLetters[id-1] = replayed
+		deadLetter = &replayed
+
+		return nil
+	})
+
+	return deadLetter, err
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"sphere-homework/app/event"
+	"time"
+)
+
+// MemoryEventOutboxRepository is the EventOutboxRepository of a MemoryStore
+type MemoryEventOutboxRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryEventOutboxRepository(store *MemoryStore) *MemoryEventOutboxRepository {
+	return &MemoryEventOutboxRepository{
+		store: store,
+	}
+}
+
+// RelayEvents hands the oldest unpublished events to publish in order, and marks those published - it stops at the first
+// event that fails to publish. Relays are serialized by the store, so the lease is not needed to keep the order.
+func (e *MemoryEventOutboxRepository) RelayEvents(limit int, lease time.Duration, publish func(event.BaseEvent) error) (int, error) {
+	e.store.mu.Lock()
+	defer e.store.mu.Unlock()
+
+	published := 0
+	for _, outboxEvent := range e.store.state.outboxEvents[e.store.state.outboxPublished:] {
+		if published == limit || publish(outboxEvent) != nil {
+			break
+		}
+
+		e.store.state.outboxPublished++
+		published++
+	}
+
+	return published, nil
+}
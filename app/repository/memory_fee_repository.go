@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// MemoryFeeRepository is the FeeRepository of a MemoryStore
+type MemoryFeeRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryFeeRepository(store *MemoryStore) *MemoryFeeRepository {
+	return &MemoryFeeRepository{
+		store: store,
+	}
+}
+
+// SetFee sets the fee of transfers to the asset, like the fees seeded by the migrations
+func (f *MemoryFeeRepository) SetFee(toAsset string, fee decimal.Decimal) {
+	_ = f.store.transaction(func(now time.Time) error {
+		f.store.state.fees[toAsset] = fee
+		return nil
+	})
+}
+
+// GetFee returns the fee, or pgx.ErrNoRows if there is none like the postgres repository
+func (f *MemoryFeeRepository) GetFee(toAsset string) (decimal.Decimal, error) {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	fee, ok := f.store.state.fees[toAsset]
+	if !ok {
+		return decimal.Zero, pgx.ErrNoRows
+	}
+
+	return fee, nil
+}
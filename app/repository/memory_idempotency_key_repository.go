@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"sphere-homework/app/model"
+	"time"
+)
+
+// MemoryIdempotencyKeyRepository is the IdempotencyKeyRepository of a MemoryStore
+type MemoryIdempotencyKeyRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryIdempotencyKeyRepository(store *MemoryStore) *MemoryIdempotencyKeyRepository {
+	return &MemoryIdempotencyKeyRepository{
+		store: store,
+	}
+}
+
+// GetIdempotencyKey returns the sender's key, or nil if the key does not exist or has expired
+func (i *MemoryIdempotencyKeyRepository) GetIdempotencyKey(sender string, key string) (*model.IdempotencyKey, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	idempotencyKey, ok := i.store.state.idempotencyKeys[idempotencyKeyKey{sender: sender, key: key}]
+	if !ok || !idempotencyKey.ExpiresAt.After(i.store.now()) {
+		return nil, nil
+	}
+
+	return &idempotencyKey, nil
+}
+
+// ReserveIdempotencyKey stores the sender's key if it does not exist yet or has expired - it returns false if the key
+// is already taken. A key reserved again once it expired is taken off the outbox row of the transfer it was reserved for
+// before.
+func (i *MemoryIdempotencyKeyRepository) ReserveIdempotencyKey(key model.IdempotencyKey) (reserved bool, err error) {
+	err = i.store.transaction(func(now time.Time) error {
+		existing, ok := i.store.state.idempotencyKeys[idempotencyKeyKey{sender: key.Sender, key: key.Key}]
+		if ok && existing.ExpiresAt.After(now) {
+			return nil
+		}
+
+		i.store.state.idempotencyKeys[idempotencyKeyKey{sender: key.Sender, key: key.Key}] = key
+
+		for transferId, transfer := range i.store.state.transfers {
+			if transfer.Sender == key.Sender && transfer.IdempotencyKey != nil && *transfer.IdempotencyKey == key.Key &&
+				transferId != key.TransferId {
+				transfer.IdempotencyKey = nil
+				i.store.state.transfers[transferId] = transfer
+			}
+		}
+
+		reserved = true
+
+		return nil
+	})
+
+	return reserved, err
+}
+
+// SetIdempotencyKeyStatusCode updates the status code replayed for the sender's key
+func (i *MemoryIdempotencyKeyRepository) SetIdempotencyKeyStatusCode(sender string, key string, statusCode int) error {
+	return i.store.transaction(func(now time.Time) error {
+		idempotencyKey, ok := i.store.state.idempotencyKeys[idempotencyKeyKey{sender: sender, key: key}]
+		if !ok {
+			return nil
+		}
+
+		idempotencyKey.StatusCode = statusCode
+		i.store.state.idempotencyKeys[idempotencyKeyKey{sender: sender, key: key}] = idempotencyKey
+
+		return nil
+	})
+}
+
+// DeleteIdempotencyKey releases a key reserved by a request that could not be completed
+func (i *MemoryIdempotencyKeyRepository) DeleteIdempotencyKey(sender string, key string) error {
+	return i.store.transaction(func(now time.Time) error {
+		delete(i.store.state.idempotencyKeys, idempotencyKeyKey{sender: sender, key: key})
+		return nil
+	})
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"slices"
+	"sphere-homework/app/event"
+	"sphere-homework/app/model"
+	"strings"
+	"time"
+)
+
+// ledgerEntryTypeOrder is the order of the ledger_entry_type enum values, which statement entries booked at the same
+// time are sorted by
+var ledgerEntryTypeOrder = map[model.LedgerEntryType]int{
+	model.FeeLedgerEntryType:      0,
+	model.TransferLedgerEntryType: 1,
+	model.RoundingLedgerEntryType: 2,
+	model.ReversalLedgerEntryType: 3,
+}
+
+// MemoryLedgerRepository is the LedgerRepository of a MemoryStore
+type MemoryLedgerRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryLedgerRepository(store *MemoryStore) *MemoryLedgerRepository {
+	return &MemoryLedgerRepository{
+		store: store,
+	}
+}
+
+// SetBalance sets the balance of the account's asset without recording ledger history, like the balances seeded by the
+// migrations
+func (l *MemoryLedgerRepository) SetBalance(account string, asset string, balance decimal.Decimal) {
+	_ = l.store.transaction(func(now time.Time) error {
+		key := ledgerKey{account: account, asset: asset}
+
+		row := l.store.state.ledger[key]
+		row.balance = balance
+		l.store.state.ledger[key] = row
+
+		return nil
+	})
+}
+
+// ApplyLedgerEntries debits or credits the entries against the ledger and records them in the ledger history, creating
+// the ledger entries of the accounts that have none
+func (l *MemoryLedgerRepository) ApplyLedgerEntries(entries ...model.LedgerEntry) {
+	_ = l.store.transaction(func(now time.Time) error {
+		l.store.applyLedgerEntries(now, entries)
+		return nil
+	})
+}
+
+func (l *MemoryLedgerRepository) InsertNewEntryIfNotExists(asset string, accountName string) error {
+	return l.store.transaction(func(now time.Time) error {
+		key := ledgerKey{account: accountName, asset: asset}
+		if _, ok := l.store.state.ledger[key]; !ok {
+			l.store.state.ledger[key] = ledgerRow{}
+		}
+
+		return nil
+	})
+}
+
+func (l *MemoryLedgerRepository) GetAccountBalances(account string) ([]model.AccountBalance, error) {
+	return l.getAccountBalances(account, nil), nil
+}
+
+func (l *MemoryLedgerRepository) GetAccountBalance(account string, asset string) (*model.AccountBalance, error) {
+	balances := l.getAccountBalances(account, &asset)
+	if len(balances) == 0 {
+		return nil, nil
+	}
+
+	return &balances[0], nil
+}
+
+func (l *MemoryLedgerRepository) getAccountBalances(account string, asset *string) []model.AccountBalance {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	pending := make(map[string]decimal.Decimal)
+	for _, transfer := range l.store.state.transfers {
+		if transfer.Sender == account && transfer.TransferStatus == model.UnsentTransferStatus {
+			pending[transfer.FromAsset] = pending[transfer.FromAsset].Add(transfer.RequestedAmount)
+		}
+	}
+
+	var balances []model.AccountBalance
+	for key, row := range l.store.state.ledger {
+		if key.account != account || (asset != nil && key.asset != *asset) {
+			continue
+		}
+
+		balances = append(balances, model.AccountBalance{
+			Asset:           key.asset,
+			Balance:         row.balance,
+			Held:            row.held,
+			PendingOutgoing: pending[key.asset],
+			Available:       row.balance.Sub(row.held),
+		})
+	}
+
+	slices.SortFunc(balances, func(a, b model.AccountBalance) int {
+		return strings.Compare(a.Asset, b.Asset)
+	})
+
+	return balances
+}
+
+// GetBalances returns the balances of the account's assets with their inflow and outflow over the last day, ordered by
+// asset
+func (l *MemoryLedgerRepository) GetBalances(account string) ([]model.LedgerBalance, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	since := l.store.now().Add(-24 * time.Hour)
+
+	flows := make(map[string]model.LedgerBalance)
+	for key, row := range l.store.state.ledger {
+		if key.account == account {
+			flows[key.asset] = model.LedgerBalance{
+				Asset:   key.asset,
+				Amount:  row.balance,
+				Inflow:  decimal.Zero,
+				Outflow: decimal.Zero,
+			}
+		}
+	}
+
+	for _, row := range l.store.state.ledgerHistory {
+		flow, ok := flows[row.entry.Asset]
+		if !ok || row.entry.Account != account || row.createdAt.Before(since) {
+			continue
+		}
+
+		if row.entry.Amount.IsPositive() {
+			flow.Inflow = flow.Inflow.Add(row.entry.Amount)
+		} else {
+			flow.Outflow = flow.Outflow.Sub(row.entry.Amount)
+		}
+		flows[row.entry.Asset] = flow
+	}
+
+	var result []model.LedgerBalance
+	for _, flow := range flows {
+		result = append(result, flow)
+	}
+
+	slices.SortFunc(result, func(a, b model.LedgerBalance) int {
+		return strings.Compare(a.Asset, b.Asset)
+	})
+
+	return result, nil
+}
+
+func (l *MemoryLedgerRepository) Transfer(transfer *model.Transfer) error {
+	// the asset registry loads missing assets through the store, so the asset is looked up outside the transaction
+	toAsset, err := l.store.assetRegistry.GetAsset(transfer.ToAsset)
+	if err != nil {
+		return err
+	}
+
+	entries, sendAmount := transferLedgerEntries(toAsset, *transfer)
+
+	return l.store.transaction(func(now time.Time) error {
+		state := &l.store.state
+
+		accounts := []ledgerKey{
+			{account: transfer.Sender, asset: transfer.FromAsset},
+			{account: transfer.Recipient, asset: transfer.ToAsset},
+			{account: RoundingAccount, asset: transfer.ToAsset},
+		}
+
+		if transfer.Sender != SystemAccount {
+			accounts = append(accounts, ledgerKey{account: SystemAccount, asset: transfer.FromAsset})
+		}
+
+		for _, key := range accounts {
+			if _, ok := state.ledger[key]; !ok {
+				return pgx.ErrNoRows
+			}
+		}
+
+		source := state.ledger[accounts[0]]
+
+		hold := l.store.getActiveHold(transfer.TransferId)
+
+		var heldForTransfer = decimal.Zero
+		if hold != nil {
+			heldForTransfer = hold.Amount
+		}
+
+		if source.balance.Sub(source.held).Add(heldForTransfer).LessThan(transfer.RequestedAmount) {
+			return ErrInsufficientBalance
+		}
+
+		if hold != nil {
+			l.store.updateHold(now, *hold, model.CapturedHoldStatus)
+		}
+
+		l.store.applyLedgerEntries(now, entries)
+
+		transfer.SentAmount = &sendAmount
+
+		if _, err := l.store.unlockAndUpdateTransfer(*transfer); err != nil {
+			return err
+		}
+
+		sentEvent, err := event.NewTransferSent(*transfer)
+		if err != nil {
+			return err
+		}
+
+		l.store.insertOutboxEvents(sentEvent)
+
+		return nil
+	})
+}
+
+func (l *MemoryLedgerRepository) GetStatement(account string, from time.Time, to time.Time, asset *string) (*model.Statement, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	var assets []string
+	openingBalances := make(map[string]decimal.Decimal)
+	for key, row := range l.store.state.ledger {
+		if key.account == account && (asset == nil || key.asset == *asset) {
+			assets = append(assets, key.asset)
+			openingBalances[key.asset] = row.balance
+		}
+	}
+	slices.Sort(assets)
+
+	var rows []ledgerHistoryRow
+	for _, row := range l.store.state.ledgerHistory {
+		if row.entry.Account != account || row.createdAt.Before(from) {
+			continue
+		}
+
+		// opening balances are derived from the current balance, since the initial balances have no ledger history
+		if openingBalance, ok := openingBalances[row.entry.Asset]; ok {
+			openingBalances[row.entry.Asset] = openingBalance.Sub(row.entry.Amount)
+		}
+
+		if row.createdAt.Before(to) && (asset == nil || row.entry.Asset == *asset) {
+			rows = append(rows, row)
+		}
+	}
+
+	slices.SortStableFunc(rows, func(a, b ledgerHistoryRow) int {
+		if c := a.createdAt.Compare(b.createdAt); c != 0 {
+			return c
+		}
+
+		if c := strings.Compare(a.entry.TransferId.String(), b.entry.TransferId.String()); c != 0 {
+			return c
+		}
+
+		return ledgerEntryTypeOrder[a.entry.Type] - ledgerEntryTypeOrder[b.entry.Type]
+	})
+
+	entries := make(map[string][]model.StatementEntry)
+	for _, row := range rows {
+		entries[row.entry.Asset] = append(entries[row.entry.Asset], model.StatementEntry{
+			CreatedAt:  row.createdAt,
+			TransferId: row.entry.TransferId,
+			Type:       row.entry.Type,
+			Asset:      row.entry.Asset,
+			Amount:     row.entry.Amount,
+		})
+	}
+
+	statement := model.Statement{
+		Account: account,
+		From:    from,
+		To:      to,
+		Assets:  []model.AssetStatement{},
+	}
+
+	for _, asset := range assets {
+		statement.Assets = append(statement.Assets, model.NewAssetStatement(asset, openingBalances[asset], entries[asset]))
+	}
+
+	return &statement, nil
+}
+
+func (l *MemoryLedgerRepository) PlaceHold(hold model.Hold) error {
+	return l.store.transaction(func(now time.Time) error {
+		key := ledgerKey{account: hold.Account, asset: hold.Asset}
+
+		row, ok := l.store.state.ledger[key]
+		if !ok || row.balance.Sub(row.held).LessThan(hold.Amount) {
+			return ErrInsufficientBalance
+		}
+
+		if _, ok := l.store.state.holds[hold.TransferId]; ok {
+			return fmt.Errorf("transfer %s already has a hold", hold.TransferId)
+		}
+
+		row.held = row.held.Add(hold.Amount)
+		l.store.state.ledger[key] = row
+
+		hold.Status = model.HeldHoldStatus
+		hold.CreatedAt = now
+		hold.UpdatedAt = now
+		l.store.state.holds[hold.TransferId] = hold
+
+		return nil
+	})
+}
+
+func (l *MemoryLedgerRepository) ReleaseHold(transferId uuid.UUID) (released bool, err error) {
+	err = l.store.transaction(func(now time.Time) error {
+		hold := l.store.getActiveHold(transferId)
+		if hold == nil {
+			return nil
+		}
+
+		l.store.updateHold(now, *hold, model.ReleasedHoldStatus)
+		released = true
+
+		return nil
+	})
+
+	return released, err
+}
+
+func (l *MemoryLedgerRepository) ReleaseExpiredHolds(expiry time.Duration, limit int) (released []model.Hold, err error) {
+	err = l.store.transaction(func(now time.Time) error {
+		var expired []model.Hold
+		for _, hold := range l.store.state.holds {
+			if _, ok := l.store.state.transfers[hold.TransferId]; ok {
+				continue
+			}
+
+			if hold.Status == model.HeldHoldStatus && hold.CreatedAt.Before(now.Add(-expiry)) {
+				expired = append(expired, hold)
+			}
+		}
+
+		slices.SortFunc(expired, func(a, b model.Hold) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+
+		for _, hold := range expired[:min(limit, len(expired))] {
+			l.store.updateHold(now, hold, model.ReleasedHoldStatus)
+			released = append(released, hold)
+		}
+
+		return nil
+	})
+
+	return released, err
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"slices"
+	"sphere-homework/app/model"
+	"strings"
+	"time"
+)
+
+// MemoryPoolRebalanceSettingRepository is the PoolRebalanceSettingRepository of a MemoryStore
+type MemoryPoolRebalanceSettingRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryPoolRebalanceSettingRepository(store *MemoryStore) *MemoryPoolRebalanceSettingRepository {
+	return &MemoryPoolRebalanceSettingRepository{
+		store: store,
+	}
+}
+
+// SetPoolRebalanceSetting sets the rebalancer setting of the asset, like the settings seeded by the migrations
+func (p *MemoryPoolRebalanceSettingRepository) SetPoolRebalanceSetting(setting model.PoolRebalanceSetting) {
+	_ = p.store.transaction(func(now time.Time) error {
+		p.store.state.poolRebalanceSettings[setting.Asset] = setting
+		return nil
+	})
+}
+
+// GetPoolRebalanceSettings returns the settings ordered by asset - unlike the postgres repository, the settings of
+// assets missing from the store's asset table are returned as well
+func (p *MemoryPoolRebalanceSettingRepository) GetPoolRebalanceSettings() ([]model.PoolRebalanceSetting, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	var settings []model.PoolRebalanceSetting
+	for _, setting := range p.store.state.poolRebalanceSettings {
+		if asset, ok := p.store.state.assets[setting.Asset]; ok && !asset.Enabled {
+			continue
+		}
+
+		settings = append(settings, setting)
+	}
+
+	slices.SortFunc(settings, func(a, b model.PoolRebalanceSetting) int {
+		return strings.Compare(a.Asset, b.Asset)
+	})
+
+	return settings, nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// MemoryRateRepository is the RateRepository of a MemoryStore
+type MemoryRateRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryRateRepository(store *MemoryStore) *MemoryRateRepository {
+	return &MemoryRateRepository{
+		store: store,
+	}
+}
+
+func (r *MemoryRateRepository) UpsertRate(fromAsset string, toAsset string, rate decimal.Decimal, timestamp time.Time) error {
+	return r.store.transaction(func(now time.Time) error {
+		r.store.state.rates[rateKey{fromAsset: fromAsset, toAsset: toAsset}] = rate
+		return nil
+	})
+}
+
+// GetRate returns the rate, or pgx.ErrNoRows if there is none like the postgres repository
+func (r *MemoryRateRepository) GetRate(fromAsset string, toAsset string) (decimal.Decimal, error) {
+	if fromAsset == toAsset {
+		return decimal.NewFromInt(1), nil
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rate, ok := r.store.state.rates[rateKey{fromAsset: fromAsset, toAsset: toAsset}]
+	if !ok {
+		return decimal.Zero, pgx.ErrNoRows
+	}
+
+	return rate, nil
+}
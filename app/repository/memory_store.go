@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"maps"
+	"slices"
+	"sphere-homework/app/event"
+	"sphere-homework/app/model"
+	"sync"
+	"time"
+)
+
+type ledgerHistoryRow struct {
+	createdAt time.Time
+	entry     model.LedgerEntry
+}
+
+type rateKey struct {
+	fromAsset string
+	toAsset   string
+}
+
+type idempotencyKeyKey struct {
+	sender string
+	key    string
+}
+
+// memoryState is the content of the tables a MemoryStore keeps
+type memoryState struct {
+	assets                map[string]model.Asset
+	ledger                map[ledgerKey]ledgerRow
+	ledgerHistory         []ledgerHistoryRow
+	holds                 map[uuid.UUID]model.Hold
+	transfers             map[uuid.UUID]model.Transfer
+	idempotencyKeys       map[idempotencyKeyKey]model.IdempotencyKey
+	outboxEvents          []event.BaseEvent
+	outboxPublished       int // number of outbox events relayed so far, the events are relayed in order
+	rates                 map[rateKey]decimal.Decimal
+	fees                  map[string]decimal.Decimal
+	transferHistory       []event.BaseEvent
+	poolRebalanceSettings map[string]model.PoolRebalanceSetting
+	deadLetters           []model.DeadLetter // the id of a dead letter is its position, starting at 1
+}
+
+// clone copies the state, so a failed transaction can restore it - rows are stored by value and the history tables
+// are only appended to, so copying the maps and slice headers is enough, except for the dead letters that are updated
+func (s memoryState) clone() memoryState {
+	s.assets = maps.Clone(s.assets)
+	s.ledger = maps.Clone(s.ledger)
+	s.holds = maps.Clone(s.holds)
+	s.transfers = maps.Clone(s.transfers)
+	s.idempotencyKeys = maps.Clone(s.idempotencyKeys)
+	s.rates = maps.Clone(s.rates)
+	s.fees = maps.Clone(s.fees)
+	s.poolRebalanceSettings = maps.Clone(s.poolRebalanceSettings)
+	s.deadLetters = slices.Clone(s.deadLetters)
+
+	return s
+}
+
+// MemoryStore keeps the tables of the in-memory repositories for tests. Transactions are serialized, which gives the
+// guarantees the row locks give the postgres repositories, and are rolled back if they return an error - the
+// repositories check the lock_id of transfers and the expiry of their leases the same way, against the store's clock.
+type MemoryStore struct {
+	mu            sync.Mutex
+	state         memoryState
+	now           func() time.Time
+	assetRegistry *AssetRegistry
+}
+
+// NewMemoryStore returns an empty store, with an asset registry that loads the assets of the store's asset table
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{
+		state: memoryState{
+			assets:                map[string]model.Asset{},
+			ledger:                map[ledgerKey]ledgerRow{},
+			holds:                 map[uuid.UUID]model.Hold{},
+			transfers:             map[uuid.UUID]model.Transfer{},
+			idempotencyKeys:       map[idempotencyKeyKey]model.IdempotencyKey{},
+			rates:                 map[rateKey]decimal.Decimal{},
+			fees:                  map[string]decimal.Decimal{},
+			poolRebalanceSettings: map[string]model.PoolRebalanceSetting{},
+		},
+		now: time.Now,
+	}
+
+	store.assetRegistry = NewAssetRegistry(NewMemoryAssetRepository(store))
+
+	return store
+}
+
+// AssetRegistry returns the registry of the store's assets, which is refreshed like the registry of the postgres
+// repositories
+func (m *MemoryStore) AssetRegistry() *AssetRegistry {
+	return m.assetRegistry
+}
+
+// SetClock replaces the clock the store uses in place of NOW(), e.g. to expire transfer lock leases
+func (m *MemoryStore) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = now
+}
+
+// OutboxEvents returns the events recorded in the event outbox so far, oldest first
+func (m *MemoryStore) OutboxEvents() []event.BaseEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]event.BaseEvent(nil), m.state.outboxEvents...)
+}
+
+// transaction runs fn with exclusive access to the state, and restores the state if fn returns an error - fn is passed
+// the transaction time, which all rows written by the transaction share like they share NOW() in postgres
+func (m *MemoryStore) transaction(fn func(now time.Time) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.state.clone()
+
+	if err := fn(m.now().UTC()); err != nil {
+		m.state = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// applyLedgerEntries debits or credits each entry against the ledger and records it in the ledger history
+func (m *MemoryStore) applyLedgerEntries(now time.Time, entries []model.LedgerEntry) {
+	for _, entry := range entries {
+		key := ledgerKey{account: entry.Account, asset: entry.Asset}
+
+		row := m.state.ledger[key]
+		row.balance = row.balance.Add(entry.Amount)
+		m.state.ledger[key] = row
+
+		m.state.ledgerHistory = append(m.state.ledgerHistory, ledgerHistoryRow{createdAt: now, entry: entry})
+	}
+}
+
+// getActiveHold returns the transfer's hold if it is still held, or nil otherwise
+func (m *MemoryStore) getActiveHold(transferId uuid.UUID) *model.Hold {
+	hold, ok := m.state.holds[transferId]
+	if !ok || hold.Status != model.HeldHoldStatus {
+		return nil
+	}
+
+	return &hold
+}
+
+// updateHold moves an active hold to captured or released, and removes its amount from the account's held funds
+func (m *MemoryStore) updateHold(now time.Time, hold model.Hold, status model.HoldStatus) {
+	hold.Status = status
+	hold.UpdatedAt = now
+	m.state.holds[hold.TransferId] = hold
+
+	key := ledgerKey{account: hold.Account, asset: hold.Asset}
+	row := m.state.ledger[key]
+	row.held = row.held.Sub(hold.Amount)
+	m.state.ledger[key] = row
+}
+
+// reverseLedgerEntries books a compensating REVERSAL entry for every ledger entry of the transfer - it returns the
+// compensating entries, none if the transfer was already reversed, or ErrReversalNotCovered if an account does not
+// have the available balance the reversal debits from it
+func (m *MemoryStore) reverseLedgerEntries(now time.Time, transferId uuid.UUID) ([]model.LedgerEntry, error) {
+	var entries []model.LedgerEntry
+	for _, row := range m.state.ledgerHistory {
+		if row.entry.TransferId != transferId {
+			continue
+		}
+
+		if row.entry.Type == model.ReversalLedgerEntryType {
+			return nil, nil
+		}
+
+		entry := row.entry
+		entry.Type = model.ReversalLedgerEntryType
+		entry.Amount = entry.Amount.Neg()
+		entries = append(entries, entry)
+	}
+
+	if err := checkReversalCovered(entries, m.state.ledger); err != nil {
+		return nil, err
+	}
+
+	m.applyLedgerEntries(now, entries)
+
+	return entries, nil
+}
+
+// unlockAndUpdateTransfer stores the outcome of processing the transfer and releases the outbox processor's lock on it,
+// if the processor still holds the lock
+func (m *MemoryStore) unlockAndUpdateTransfer(transfer model.Transfer) (*model.Transfer, error) {
+	stored, ok := m.state.transfers[transfer.TransferId]
+	if !ok || stored.LockId == nil || transfer.LockId == nil || *stored.LockId != *transfer.LockId {
+		return nil, ErrLockReclaimed
+	}
+
+	stored.LockId = nil
+	stored.LockedAt = nil
+	stored.LockExpiresAt = nil
+	stored.SentAt = transfer.SentAt
+	stored.TransferStatus = transfer.TransferStatus
+	stored.SentAmount = transfer.SentAmount
+	stored.FailureReason = transfer.FailureReason
+	stored.SettledAt = transfer.SettledAt
+	m.state.transfers[transfer.TransferId] = stored
+
+	return &stored, nil
+}
+
+// insertOutboxEvents records the events in the event outbox
+func (m *MemoryStore) insertOutboxEvents(events ...*event.BaseEvent) {
+	for _, e := range events {
+		m.state.outboxEvents = append(m.state.outboxEvents, *e)
+	}
+}
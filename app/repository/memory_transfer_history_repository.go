@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"sphere-homework/app/event"
+	"time"
+)
+
+// MemoryTransferHistoryRepository is the TransferHistoryRepository of a MemoryStore
+type MemoryTransferHistoryRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTransferHistoryRepository(store *MemoryStore) *MemoryTransferHistoryRepository {
+	return &MemoryTransferHistoryRepository{
+		store: store,
+	}
+}
+
+func (t *MemoryTransferHistoryRepository) InsertTransferHistory(event event.BaseEvent) error {
+	return t.store.transaction(func(now time.Time) error {
+		for _, recorded := range t.store.state.transferHistory {
+			if recorded.EventType == event.EventType && recorded.Timestamp == event.Timestamp && bytes.Equal(recorded.Payload, event.Payload) {
+				return nil
+			}
+		}
+
+		t.store.state.transferHistory = append(t.store.state.transferHistory, event)
+
+		return nil
+	})
+}
+
+func (t *MemoryTransferHistoryRepository) GetTransferEvent(transferId uuid.UUID, eventType string) (*event.BaseEvent, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	var first *event.BaseEvent
+	for _, recorded := range t.store.state.transferHistory {
+		var payload struct {
+			TransferId string `json:"transfer_id"`
+		}
+
+		if recorded.EventType != eventType || json.Unmarshal(recorded.Payload, &payload) != nil || payload.TransferId != transferId.String() {
+			continue
+		}
+
+		if first == nil || recorded.Timestamp < first.Timestamp {
+			first = &recorded
+		}
+	}
+
+	return first, nil
+}
@@ -0,0 +1,486 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"slices"
+	"sphere-homework/app/event"
+	"sphere-homework/app/model"
+	"strings"
+	"time"
+)
+
+// MemoryTransferRepository is the TransferRepository of a MemoryStore
+type MemoryTransferRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTransferRepository(store *MemoryStore) *MemoryTransferRepository {
+	return &MemoryTransferRepository{
+		store: store,
+	}
+}
+
+func (t *MemoryTransferRepository) InsertOutgoingTransfer(transfer model.Transfer) (inserted bool, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		for _, existing := range t.store.state.transfers {
+			if existing.TransferId == transfer.TransferId || (transfer.IdempotencyKey != nil && existing.IdempotencyKey != nil &&
+				existing.Sender == transfer.Sender && *existing.IdempotencyKey == *transfer.IdempotencyKey) {
+				return nil
+			}
+		}
+
+		t.store.state.transfers[transfer.TransferId] = model.Transfer{
+			TransferId:      transfer.TransferId,
+			CreatedAt:       transfer.CreatedAt,
+			FromAsset:       transfer.FromAsset,
+			ToAsset:         transfer.ToAsset,
+			RequestedAmount: transfer.RequestedAmount,
+			Fee:             transfer.Fee,
+			NetAmount:       transfer.RequestedAmount.Sub(transfer.Fee),
+			Rate:            transfer.Rate,
+			Sender:          transfer.Sender,
+			Recipient:       transfer.Recipient,
+			TransferStatus:  model.UnsentTransferStatus,
+			TransferType:    transfer.TransferType,
+			IdempotencyKey:  transfer.IdempotencyKey,
+		}
+		inserted = true
+
+		return nil
+	})
+
+	return inserted, err
+}
+
+func (t *MemoryTransferRepository) RejectTransfer(transfer model.Transfer, reason string) (rejected *model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		if _, ok := t.store.state.transfers[transfer.TransferId]; ok {
+			return nil
+		}
+
+		stored := model.Transfer{
+			TransferId:      transfer.TransferId,
+			CreatedAt:       transfer.CreatedAt,
+			FromAsset:       transfer.FromAsset,
+			ToAsset:         transfer.ToAsset,
+			RequestedAmount: transfer.RequestedAmount,
+			Fee:             transfer.Fee,
+			NetAmount:       transfer.RequestedAmount.Sub(transfer.Fee),
+			Rate:            transfer.Rate,
+			Sender:          transfer.Sender,
+			Recipient:       transfer.Recipient,
+			TransferStatus:  model.FailedTransferStatus,
+			FailureReason:   &reason,
+			TransferType:    transfer.TransferType,
+		}
+		t.store.state.transfers[transfer.TransferId] = stored
+
+		if hold := t.store.getActiveHold(transfer.TransferId); hold != nil {
+			t.store.updateHold(now, *hold, model.ReleasedHoldStatus)
+		}
+
+		failedEvent, err := event.NewTransferFailed(stored)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(failedEvent)
+		rejected = &stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+// isDue reports whether the transfer is unsent, not being processed and due for an attempt
+func isDue(transfer model.Transfer, now time.Time) bool {
+	return transfer.TransferStatus == model.UnsentTransferStatus &&
+		transfer.LockId == nil &&
+		(transfer.NextAttemptAt == nil || !transfer.NextAttemptAt.After(now))
+}
+
+func (t *MemoryTransferRepository) ClaimUnsentTransfers(toAsset string, limit int, lease time.Duration) (claimed []model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		candidates := t.selectTransfers(func(transfer model.Transfer) bool {
+			return transfer.ToAsset == toAsset && isDue(transfer, now)
+		}, compareCreatedAt, limit)
+
+		for _, transfer := range candidates {
+			lockId := uuid.New()
+			lockedAt := now
+			lockExpiresAt := now.Add(lease)
+
+			transfer.LockId = &lockId
+			transfer.LockedAt = &lockedAt
+			transfer.LockExpiresAt = &lockExpiresAt
+			transfer.AttemptCount++
+
+			t.store.state.transfers[transfer.TransferId] = transfer
+			claimed = append(claimed, transfer)
+		}
+
+		return nil
+	})
+
+	return claimed, err
+}
+
+func (t *MemoryTransferRepository) FailTransfer(transfer model.Transfer, reason string) (failed *model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		transfer.TransferStatus = model.FailedTransferStatus
+		transfer.FailureReason = &reason
+
+		updated, err := t.store.unlockAndUpdateTransfer(transfer)
+		if err != nil {
+			return err
+		}
+
+		// the transfer will not be retried, so the funds held for it are available again
+		if hold := t.store.getActiveHold(transfer.TransferId); hold != nil {
+			t.store.updateHold(now, *hold, model.ReleasedHoldStatus)
+		}
+
+		failedEvent, err := event.NewTransferFailed(*updated)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(failedEvent)
+		failed = updated
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return failed, nil
+}
+
+func (t *MemoryTransferRepository) RetryTransfer(transfer model.Transfer, nextAttemptAt time.Time, lastError string) (retried *model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		stored, ok := t.store.state.transfers[transfer.TransferId]
+		if !ok || stored.LockId == nil || transfer.LockId == nil || *stored.LockId != *transfer.LockId {
+			return ErrLockReclaimed
+		}
+
+		stored.LockId = nil
+		stored.LockedAt = nil
+		stored.LockExpiresAt = nil
+		stored.NextAttemptAt = &nextAttemptAt
+		stored.LastError = &lastError
+		t.store.state.transfers[transfer.TransferId] = stored
+		retried = &stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return retried, nil
+}
+
+func (t *MemoryTransferRepository) CancelTransfer(transferId uuid.UUID) (cancelled *model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		stored, ok := t.store.state.transfers[transferId]
+		if !ok || stored.TransferStatus != model.UnsentTransferStatus || stored.LockId != nil {
+			return nil
+		}
+
+		stored.TransferStatus = model.CancelledTransferStatus
+		t.store.state.transfers[transferId] = stored
+
+		if hold := t.store.getActiveHold(transferId); hold != nil {
+			t.store.updateHold(now, *hold, model.ReleasedHoldStatus)
+		}
+
+		cancelledEvent, err := event.NewTransferCancelled(stored)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(cancelledEvent)
+		cancelled = &stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+func (t *MemoryTransferRepository) CompleteTransfer(transferId uuid.UUID) (completed *model.Transfer, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		stored, ok := t.store.state.transfers[transferId]
+		if !ok || stored.TransferStatus != model.SentTransferStatus {
+			return nil
+		}
+
+		stored.TransferStatus = model.CompletedTransferStatus
+		stored.SettledAt = &now
+		t.store.state.transfers[transferId] = stored
+
+		completedEvent, err := event.NewTransferCompleted(stored)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(completedEvent)
+		completed = &stored
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+func (t *MemoryTransferRepository) ReverseTransfer(transferId uuid.UUID, reason string, statuses ...model.TransferStatus) (reversal *model.Reversal, err error) {
+	err = t.store.transaction(func(now time.Time) error {
+		stored, ok := t.store.state.transfers[transferId]
+		if !ok || !slices.Contains(statuses, stored.TransferStatus) {
+			return nil
+		}
+
+		stored.TransferStatus = model.FailedTransferStatus
+		stored.FailureReason = &reason
+		stored.SettledAt = &now
+		t.store.state.transfers[transferId] = stored
+
+		entries, err := t.store.reverseLedgerEntries(now, transferId)
+		if err != nil {
+			return err
+		}
+
+		reversal = &model.Reversal{
+			Transfer: stored,
+			Reason:   reason,
+			Entries:  entries,
+		}
+
+		failedEvent, err := event.NewTransferFailed(stored)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(failedEvent)
+
+		// a transfer that never reached the ledger only fails, there is nothing to reverse
+		if len(entries) > 0 {
+			reversedEvent, err := event.NewTransferReversed(*reversal)
+			if err != nil {
+				return err
+			}
+
+			t.store.insertOutboxEvents(reversedEvent)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+func (t *MemoryTransferRepository) GetUnsettledTransfers(sentBefore time.Time, limit int) ([]model.Transfer, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	return t.selectTransfers(func(transfer model.Transfer) bool {
+		return transfer.TransferStatus == model.SentTransferStatus && transfer.SentAt != nil && transfer.SentAt.Before(sentBefore)
+	}, func(a, b model.Transfer) int {
+		return a.SentAt.Compare(*b.SentAt)
+	}, limit), nil
+}
+
+func (t *MemoryTransferRepository) GetTransfer(transferId uuid.UUID) (*model.Transfer, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	transfer, ok := t.store.state.transfers[transferId]
+	if !ok {
+		return nil, nil
+	}
+
+	return &transfer, nil
+}
+
+func (t *MemoryTransferRepository) GetUnsentTransferAssets() ([]string, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	now := t.store.now()
+
+	var assets []string
+	for _, transfer := range t.store.state.transfers {
+		if isDue(transfer, now) && !slices.Contains(assets, transfer.ToAsset) {
+			assets = append(assets, transfer.ToAsset)
+		}
+	}
+	slices.Sort(assets)
+
+	return assets, nil
+}
+
+func (t *MemoryTransferRepository) GetExpiredLocks(limit int) ([]model.Transfer, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	now := t.store.now()
+
+	return t.selectTransfers(func(transfer model.Transfer) bool {
+		return transfer.LockId != nil && transfer.LockExpiresAt.Before(now)
+	}, func(a, b model.Transfer) int {
+		return a.LockExpiresAt.Compare(*b.LockExpiresAt)
+	}, limit), nil
+}
+
+func (t *MemoryTransferRepository) ReclaimLock(transfer model.Transfer) (reclaim model.LockReclaim, recovered *model.Transfer, err error) {
+	reclaim = model.NoLockReclaim
+
+	err = t.store.transaction(func(now time.Time) error {
+		locked, ok := t.store.state.transfers[transfer.TransferId]
+		if !ok || locked.LockId == nil || transfer.LockId == nil || *locked.LockId != *transfer.LockId || !locked.LockExpiresAt.Before(now) {
+			return nil
+		}
+
+		var sentAt *time.Time
+		var sentAmount *decimal.Decimal
+		for _, row := range t.store.state.ledgerHistory {
+			if row.entry.TransferId != locked.TransferId {
+				continue
+			}
+
+			if sentAt == nil || row.createdAt.Before(*sentAt) {
+				createdAt := row.createdAt
+				sentAt = &createdAt
+			}
+
+			if row.entry.Account == locked.Recipient && row.entry.Asset == locked.ToAsset && row.entry.Type == model.TransferLedgerEntryType {
+				amount := row.entry.Amount
+				if sentAmount != nil {
+					amount = amount.Add(*sentAmount)
+				}
+				sentAmount = &amount
+			}
+		}
+
+		if sentAt == nil {
+			locked.LockId = nil
+			locked.LockedAt = nil
+			locked.LockExpiresAt = nil
+			t.store.state.transfers[locked.TransferId] = locked
+			reclaim = model.ReleasedLockReclaim
+
+			return nil
+		}
+
+		locked.TransferStatus = model.SentTransferStatus
+		locked.SentAt = sentAt
+		locked.SentAmount = sentAmount
+
+		// internal transfers are completed once sent, there is no rail to confirm them
+		if locked.TransferType == model.InternalTransferType {
+			locked.TransferStatus = model.CompletedTransferStatus
+			locked.SettledAt = sentAt
+		}
+
+		var err error
+		recovered, err = t.store.unlockAndUpdateTransfer(locked)
+		if err != nil {
+			return err
+		}
+
+		sentEvent, err := event.NewTransferSent(*recovered)
+		if err != nil {
+			return err
+		}
+
+		t.store.insertOutboxEvents(sentEvent)
+		reclaim = model.RecoveredLockReclaim
+
+		return nil
+	})
+
+	if err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	return reclaim, recovered, nil
+}
+
+func (t *MemoryTransferRepository) ListAccountTransfers(filter model.TransferFilter) ([]model.Transfer, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	matches := func(transfer model.Transfer) bool {
+		switch {
+		case transfer.Sender != filter.Account && transfer.Recipient != filter.Account:
+			return false
+		case filter.Status != nil && transfer.TransferStatus != *filter.Status:
+			return false
+		case filter.Asset != nil && transfer.FromAsset != *filter.Asset && transfer.ToAsset != *filter.Asset:
+			return false
+		case filter.TransferType != nil && transfer.TransferType != *filter.TransferType:
+			return false
+		case filter.CreatedFrom != nil && transfer.CreatedAt.Before(*filter.CreatedFrom):
+			return false
+		case filter.CreatedTo != nil && !transfer.CreatedAt.Before(*filter.CreatedTo):
+			return false
+		case filter.After != nil && compareCreatedAt(transfer, model.Transfer{CreatedAt: filter.After.CreatedAt, TransferId: filter.After.TransferId}) >= 0:
+			return false
+		default:
+			return true
+		}
+	}
+
+	// most recent first
+	return t.selectTransfers(matches, func(a, b model.Transfer) int {
+		return compareCreatedAt(b, a)
+	}, filter.Limit), nil
+}
+
+// compareCreatedAt orders transfers by created_at, transfer_id
+func compareCreatedAt(a, b model.Transfer) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.TransferId.String(), b.TransferId.String())
+}
+
+// selectTransfers returns up to limit of the transfers matching the filter in the given order, the store must be locked
+func (t *MemoryTransferRepository) selectTransfers(filter func(model.Transfer) bool, compare func(a, b model.Transfer) int, limit int) []model.Transfer {
+	var transfers []model.Transfer
+	for _, transfer := range t.store.state.transfers {
+		if filter(transfer) {
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	slices.SortFunc(transfers, compare)
+
+	if len(transfers) > limit {
+		transfers = transfers[:limit]
+	}
+
+	return transfers
+}
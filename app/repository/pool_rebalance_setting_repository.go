@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sphere-homework/app/model"
+)
+
+// PoolRebalanceSettingRepository keeps the settings of the system pool rebalancer per asset
+type PoolRebalanceSettingRepository interface {
+	GetPoolRebalanceSettings() ([]model.PoolRebalanceSetting, error)
+}
+
+type PostgresPoolRebalanceSettingRepository struct {
+	db  *pgxpool.Pool
+	ctx context.Context
+}
+
+func NewPoolRebalanceSettingRepository(db *pgxpool.Pool, ctx context.Context) PostgresPoolRebalanceSettingRepository {
+	return PostgresPoolRebalanceSettingRepository{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// GetPoolRebalanceSettings returns the settings of the enabled assets, ordered by asset
+func (p *PostgresPoolRebalanceSettingRepository) GetPoolRebalanceSettings() ([]model.PoolRebalanceSetting, error) {
+	sql := `
+		SELECT s.asset, s.imbalance_threshold, s.minimum_balance, s.top_up_amount, s.required_balance_for_top_up
+		FROM pool_rebalance_setting s
+		JOIN asset a ON a.code = s.asset
+		WHERE a.enabled
+		ORDER BY s.asset`
+
+	rows, err := p.db.Query(p.ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []model.PoolRebalanceSetting
+	for rows.Next() {
+		var setting model.PoolRebalanceSetting
+		err := rows.Scan(
+			&setting.Asset,
+			&setting.ImbalanceThreshold,
+			&setting.MinimumBalance,
+			&setting.TopUpAmount,
+			&setting.RequiredBalanceForTopUp,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		settings = append(settings, setting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
@@ -3,25 +3,31 @@ package repository
 import (
 	"context"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"time"
 )
 
-type RateRepository struct {
+type RateRepository interface {
+	UpsertRate(fromAsset string, toAsset string, rate decimal.Decimal, timestamp time.Time) error
+	GetRate(fromAsset string, toAsset string) (decimal.Decimal, error)
+}
+
+type PostgresRateRepository struct {
 	db     *pgxpool.Pool
 	ctx    context.Context
 	logger *zap.Logger
 }
 
-func NewRateRepository(db *pgxpool.Pool, ctx context.Context, logger *zap.Logger) RateRepository {
-	return RateRepository{
+func NewRateRepository(db *pgxpool.Pool, ctx context.Context, logger *zap.Logger) PostgresRateRepository {
+	return PostgresRateRepository{
 		db:     db,
 		ctx:    ctx,
 		logger: logger,
 	}
 }
 
-func (r *RateRepository) UpsertRate(fromAsset string, toAsset string, rate float64, timestamp time.Time) error {
+func (r *PostgresRateRepository) UpsertRate(fromAsset string, toAsset string, rate decimal.Decimal, timestamp time.Time) error {
 	tx, err := r.db.Begin(r.ctx)
 	defer func() {
 		var err error
@@ -68,9 +74,9 @@ func (r *RateRepository) UpsertRate(fromAsset string, toAsset string, rate float
 	return nil
 }
 
-func (r *RateRepository) GetRate(fromAsset string, toAsset string) (float64, error) {
+func (r *PostgresRateRepository) GetRate(fromAsset string, toAsset string) (decimal.Decimal, error) {
 	if fromAsset == toAsset {
-		return 1.0, nil
+		return decimal.NewFromInt(1), nil
 	}
 
 	sql := `
@@ -79,11 +85,11 @@ func (r *RateRepository) GetRate(fromAsset string, toAsset string) (float64, err
 		WHERE from_asset = $1 AND to_asset = $2
 	`
 
-	var rate float64
+	var rate decimal.Decimal
 	err := r.db.QueryRow(r.ctx, sql, fromAsset, toAsset).Scan(&rate)
 
 	if err != nil {
-		return 0, err
+		return decimal.Zero, err
 	}
 
 	return rate, nil
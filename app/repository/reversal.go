@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"sphere-homework/app/model"
+)
+
+// ErrReversalNotCovered is returned when an account does not have the available balance the reversal debits from it,
+// e.g. because the recipient already spent the funds - the reversal has to be settled manually
+var ErrReversalNotCovered = errors.New("available balance does not cover the reversal")
+
+// reverseLedgerEntries books a compensating REVERSAL entry for every ledger entry of the transfer, which restores the
+// balances of all accounts the transfer touched - it returns the compensating entries, none if the transfer was
+// already reversed
+func reverseLedgerEntries(ctx context.Context, tx pgx.Tx, transferId uuid.UUID) ([]model.LedgerEntry, error) {
+	query := `
+		SELECT account, asset, amount
+		FROM ledger_history
+		WHERE transfer_id = $1
+		AND NOT EXISTS (SELECT 1 FROM ledger_history WHERE transfer_id = $1 AND ledger_entry_type = $2)
+		ORDER BY created_at
+	`
+
+	rows, err := tx.Query(ctx, query, transferId, model.ReversalLedgerEntryType)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []model.LedgerEntry
+	for rows.Next() {
+		entry := model.LedgerEntry{TransferId: transferId, Type: model.ReversalLedgerEntryType}
+		if err := rows.Scan(&entry.Account, &entry.Asset, &entry.Amount); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		entry.Amount = entry.Amount.Neg()
+		entries = append(entries, entry)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	accounts := make([]ledgerKey, len(entries))
+	for i, entry := range entries {
+		accounts[i] = ledgerKey{account: entry.Account, asset: entry.Asset}
+	}
+
+	ledger, err := lockLedgerEntries(ctx, tx, accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkReversalCovered(entries, ledger); err != nil {
+		return nil, err
+	}
+
+	if err := applyLedgerEntries(ctx, tx, entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// checkReversalCovered returns ErrReversalNotCovered if the available balance of an account does not cover what the
+// entries debit from it - the rounding account is exempt, since rounding up sent amounts can leave it negative
+func checkReversalCovered(entries []model.LedgerEntry, ledger map[ledgerKey]ledgerRow) error {
+	amounts := make(map[ledgerKey]decimal.Decimal)
+	for _, entry := range entries {
+		key := ledgerKey{account: entry.Account, asset: entry.Asset}
+		amounts[key] = amounts[key].Add(entry.Amount)
+	}
+
+	for key, amount := range amounts {
+		if key.account == RoundingAccount || !amount.IsNegative() {
+			continue
+		}
+
+		row := ledger[key]
+		if row.balance.Sub(row.held).Add(amount).IsNegative() {
+			return fmt.Errorf("%w: %s has %s %s available", ErrReversalNotCovered, key.account, row.balance.Sub(row.held).String(), key.asset)
+		}
+	}
+
+	return nil
+}
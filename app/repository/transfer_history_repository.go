@@ -2,27 +2,39 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"sphere-homework/app/event"
 	"time"
 )
 
-type TransferHistoryRepository struct {
+// TransferHistoryRepository records the transfer events consumed from the event bus
+type TransferHistoryRepository interface {
+	InsertTransferHistory(event event.BaseEvent) error
+	GetTransferEvent(transferId uuid.UUID, eventType string) (*event.BaseEvent, error)
+}
+
+type PostgresTransferHistoryRepository struct {
 	db  *pgxpool.Pool
 	ctx context.Context
 }
 
-func NewTransferHistoryRepository(db *pgxpool.Pool, ctx context.Context) TransferHistoryRepository {
-	return TransferHistoryRepository{
+func NewTransferHistoryRepository(db *pgxpool.Pool, ctx context.Context) PostgresTransferHistoryRepository {
+	return PostgresTransferHistoryRepository{
 		db:  db,
 		ctx: ctx,
 	}
 }
 
-func (t *TransferHistoryRepository) InsertTransferHistory(event event.BaseEvent) error {
+// InsertTransferHistory records the event - an event that was already recorded, e.g. because kafka redelivered it, is
+// recorded once
+func (t *PostgresTransferHistoryRepository) InsertTransferHistory(event event.BaseEvent) error {
 	sql := `
 		INSERT INTO transfer_history (created_at, event_type, sender, event)
 		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
 	`
 
 	timestamp := time.UnixMilli(event.Timestamp)
@@ -30,3 +42,36 @@ func (t *TransferHistoryRepository) InsertTransferHistory(event event.BaseEvent)
 
 	return err
 }
+
+// GetTransferEvent returns the first recorded event of the given type for the transfer, or nil if there is none
+func (t *PostgresTransferHistoryRepository) GetTransferEvent(transferId uuid.UUID, eventType string) (*event.BaseEvent, error) {
+	sql := `
+		SELECT created_at, event_type, sender, event
+		FROM transfer_history
+		WHERE event->>'transfer_id' = $1
+		AND event_type = $2
+		ORDER BY created_at
+		LIMIT 1
+	`
+
+	var timestamp time.Time
+	var transferEvent event.BaseEvent
+	err := t.db.QueryRow(t.ctx, sql, transferId.String(), eventType).Scan(
+		&timestamp,
+		&transferEvent.EventType,
+		&transferEvent.Sender,
+		&transferEvent.Payload,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	transferEvent.Timestamp = timestamp.UnixMilli()
+
+	return &transferEvent, nil
+}
@@ -7,127 +7,342 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"sphere-homework/app/event"
 	"sphere-homework/app/model"
+	"strings"
 	"time"
 )
 
-type TransferRepository struct {
+const transferColumns = `transfer_id, created_at, sent_at, from_asset, to_asset, requested_amount, fee, net_amount, rate, sent_amount, sender, recipient, status, failure_reason, transfer_type, lock_id, idempotency_key, settled_at, locked_at, lock_expires_at, attempt_count, next_attempt_at, last_error`
+
+// ErrLockReclaimed is returned when the outcome of a transfer is stored by a processor that no longer holds its lock
+var ErrLockReclaimed = errors.New("transfer lock was reclaimed or transfer not found")
+
+// TransferRepository keeps the transfer outbox - outbox processors claim unsent transfers with a lock_id, and the outcome
+// of a transfer is only stored by the processor still holding its lock
+type TransferRepository interface {
+	InsertOutgoingTransfer(transfer model.Transfer) (bool, error)
+	RejectTransfer(transfer model.Transfer, reason string) (*model.Transfer, error)
+	ClaimUnsentTransfers(toAsset string, limit int, lease time.Duration) ([]model.Transfer, error)
+	FailTransfer(transfer model.Transfer, reason string) (*model.Transfer, error)
+	RetryTransfer(transfer model.Transfer, nextAttemptAt time.Time, lastError string) (*model.Transfer, error)
+	CancelTransfer(transferId uuid.UUID) (*model.Transfer, error)
+	CompleteTransfer(transferId uuid.UUID) (*model.Transfer, error)
+	ReverseTransfer(transferId uuid.UUID, reason string, statuses ...model.TransferStatus) (*model.Reversal, error)
+	GetUnsettledTransfers(sentBefore time.Time, limit int) ([]model.Transfer, error)
+	GetTransfer(transferId uuid.UUID) (*model.Transfer, error)
+	GetUnsentTransferAssets() ([]string, error)
+	GetExpiredLocks(limit int) ([]model.Transfer, error)
+	ReclaimLock(transfer model.Transfer) (model.LockReclaim, *model.Transfer, error)
+	ListAccountTransfers(filter model.TransferFilter) ([]model.Transfer, error)
+}
+
+type PostgresTransferRepository struct {
 	db  *pgxpool.Pool
 	ctx context.Context
 }
 
-func NewTransferRepository(db *pgxpool.Pool, ctx context.Context) TransferRepository {
-	return TransferRepository{
+func NewTransferRepository(db *pgxpool.Pool, ctx context.Context) PostgresTransferRepository {
+	return PostgresTransferRepository{
 		db:  db,
 		ctx: ctx,
 	}
 }
 
-func (t *TransferRepository) InsertOutgoingTransfer(transfer model.Transfer) error {
+// InsertOutgoingTransfer records the transfer in the outbox under the transfer id assigned when the transfer was accepted -
+// it returns false if the transfer was already recorded, or the sender's idempotency key is on the row of another transfer
+func (t *PostgresTransferRepository) InsertOutgoingTransfer(transfer model.Transfer) (bool, error) {
 	sql := `
-		INSERT INTO outgoing_transfer (transfer_id, created_at, from_asset, to_asset, requested_amount, fee, net_amount, sender, recipient, status, transfer_type, rate) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+		INSERT INTO outgoing_transfer (transfer_id, created_at, from_asset, to_asset, requested_amount, fee, net_amount, sender, recipient, status, transfer_type, rate, idempotency_key) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT DO NOTHING`
 
-	_, err := t.db.Exec(t.ctx, sql, uuid.New(), time.Now().UTC(), transfer.FromAsset, transfer.ToAsset, transfer.RequestedAmount, transfer.Fee, transfer.RequestedAmount-transfer.Fee, transfer.Sender, transfer.Recipient, model.UnsentTransferStatus, transfer.TransferType, transfer.Rate)
+	tag, err := t.db.Exec(t.ctx, sql, transfer.TransferId, transfer.CreatedAt, transfer.FromAsset, transfer.ToAsset, transfer.RequestedAmount, transfer.Fee, transfer.RequestedAmount.Sub(transfer.Fee), transfer.Sender, transfer.Recipient, model.UnsentTransferStatus, transfer.TransferType, transfer.Rate, transfer.IdempotencyKey)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return tag.RowsAffected() > 0, nil
 }
 
-func (t *TransferRepository) LockTransfer(transferId uuid.UUID) (*model.Transfer, error) {
+// RejectTransfer records a transfer the outbox cannot accept, e.g. because the sender's idempotency key is still on the
+// row of another transfer, as failed without its idempotency key - it releases the funds held for it and records the
+// transfer failed event in the same transaction, and returns nil if the transfer was already recorded
+func (t *PostgresTransferRepository) RejectTransfer(transfer model.Transfer, reason string) (rejected *model.Transfer, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || rejected == nil {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
+	sql := `
+		INSERT INTO outgoing_transfer (transfer_id, created_at, from_asset, to_asset, requested_amount, fee, net_amount, sender, recipient, status, transfer_type, rate, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (transfer_id) DO NOTHING
+		RETURNING ` + transferColumns
+
+	rejected, err = scanTransfer(tx.QueryRow(t.ctx, sql, transfer.TransferId, transfer.CreatedAt, transfer.FromAsset, transfer.ToAsset, transfer.RequestedAmount, transfer.Fee, transfer.RequestedAmount.Sub(transfer.Fee), transfer.Sender, transfer.Recipient, model.FailedTransferStatus, transfer.TransferType, transfer.Rate, reason))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	hold, err := getActiveHold(t.ctx, tx, transfer.TransferId)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold != nil {
+		if err = updateHold(t.ctx, tx, *hold, model.ReleasedHoldStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	failedEvent, err := event.NewTransferFailed(*rejected)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, failedEvent); err != nil {
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+// ClaimUnsentTransfers leases up to limit of the oldest unsent transfers to the given destination asset that are due for
+// an attempt to the calling outbox processor, skipping transfers being claimed by other processors - a lock is reclaimed
+// by the lock reaper if the processor does not finish the transfer before the lease expires
+func (t *PostgresTransferRepository) ClaimUnsentTransfers(toAsset string, limit int, lease time.Duration) ([]model.Transfer, error) {
 	sql := `
 		UPDATE outgoing_transfer 
-		SET lock_id = uuid_generate_v4()
+		SET lock_id = uuid_generate_v4(), locked_at = NOW(), lock_expires_at = NOW() + make_interval(secs => $4),
+			attempt_count = attempt_count + 1
+		WHERE transfer_id IN (
+			SELECT transfer_id FROM outgoing_transfer
+			WHERE status = $1
+			AND lock_id IS NULL
+			AND to_asset = $2
+			AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+			ORDER BY created_at LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + transferColumns
+
+	rows, err := t.db.Query(t.ctx, sql, model.UnsentTransferStatus, toAsset, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []model.Transfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		transfers = append(transfers, *transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// unlockAndUpdateTransfer persists the outcome of processing the transfer and releases the outbox processor's lock on it
+func unlockAndUpdateTransfer(ctx context.Context, tx pgx.Tx, transfer model.Transfer) (*model.Transfer, error) {
+	sql := `
+		UPDATE outgoing_transfer 
+		SET lock_id = NULL, locked_at = NULL, lock_expires_at = NULL,
+			sent_at = $2, status = $3, sent_amount = $4, failure_reason = $5, settled_at = $6
 		WHERE transfer_id = $1
-		AND lock_id IS NULL
-		RETURNING transfer_id, created_at, from_asset, to_asset, requested_amount, fee, net_amount, sender, recipient, status, transfer_type, rate, lock_id`
+		AND lock_id = $7
+		RETURNING ` + transferColumns
 
-	var transfer model.Transfer
-	err := t.db.QueryRow(t.ctx, sql, transferId).Scan(
-		&transfer.TransferId,
-		&transfer.CreatedAt,
-		&transfer.FromAsset,
-		&transfer.ToAsset,
-		&transfer.RequestedAmount,
-		&transfer.Fee,
-		&transfer.NetAmount,
-		&transfer.Sender,
-		&transfer.Recipient,
-		&transfer.TransferStatus,
-		&transfer.TransferType,
-		&transfer.Rate,
-		&transfer.LockId,
-	)
+	// the lock id check makes sure a processor whose lock was reclaimed cannot overwrite the outcome of another processor
+	updatedTransfer, err := scanTransfer(tx.QueryRow(ctx, sql, transfer.TransferId, transfer.SentAt, transfer.TransferStatus, transfer.SentAmount, transfer.FailureReason, transfer.SettledAt, transfer.LockId))
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, fmt.Errorf("transfer already locked or not found")
+		return nil, ErrLockReclaimed
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &transfer, nil
+	return updatedTransfer, nil
+}
+
+// FailTransfer marks a locked transfer that could not be processed as failed, releases the funds held for it and
+// records the transfer failed event, all in one transaction
+func (t *PostgresTransferRepository) FailTransfer(transfer model.Transfer, reason string) (failed *model.Transfer, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
+	transfer.TransferStatus = model.FailedTransferStatus
+	transfer.FailureReason = &reason
+
+	failed, err = unlockAndUpdateTransfer(t.ctx, tx, transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transfer will not be retried, so the funds held for it are available again
+	hold, err := getActiveHold(t.ctx, tx, transfer.TransferId)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold != nil {
+		if err = updateHold(t.ctx, tx, *hold, model.ReleasedHoldStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	failedEvent, err := event.NewTransferFailed(*failed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, failedEvent); err != nil {
+		return nil, err
+	}
+
+	return failed, nil
 }
 
-func (t *TransferRepository) UnlockAndUpdateTransfer(transfer model.Transfer) (*model.Transfer, error) {
+// RetryTransfer releases the lock of a transfer that failed with a transient error, so it is claimed again once
+// nextAttemptAt passed - the funds held for it stay held
+func (t *PostgresTransferRepository) RetryTransfer(transfer model.Transfer, nextAttemptAt time.Time, lastError string) (*model.Transfer, error) {
 	sql := `
-		UPDATE outgoing_transfer 
-		SET lock_id = NULL, sent_at = $2, status = $3, sent_amount = $4
+		UPDATE outgoing_transfer
+		SET lock_id = NULL, locked_at = NULL, lock_expires_at = NULL, next_attempt_at = $3, last_error = $4
 		WHERE transfer_id = $1
-		AND lock_id IS NOT NULL
-		RETURNING transfer_id, created_at, sent_at, from_asset, to_asset, requested_amount, fee, net_amount, rate, sent_amount, sender, recipient, status, failure_reason, transfer_type, lock_id
-		`
-
-	var updatedTransfer model.Transfer
-	err := t.db.QueryRow(t.ctx, sql, transfer.TransferId, transfer.SentAt, transfer.TransferStatus, transfer.SentAmount).Scan(
-		&updatedTransfer.TransferId,
-		&updatedTransfer.CreatedAt,
-		&updatedTransfer.SentAt,
-		&updatedTransfer.FromAsset,
-		&updatedTransfer.ToAsset,
-		&updatedTransfer.RequestedAmount,
-		&updatedTransfer.Fee,
-		&updatedTransfer.NetAmount,
-		&updatedTransfer.Rate,
-		&updatedTransfer.SentAmount,
-		&updatedTransfer.Sender,
-		&updatedTransfer.Recipient,
-		&updatedTransfer.TransferStatus,
-		&updatedTransfer.FailureReason,
-		&updatedTransfer.TransferType,
-		&updatedTransfer.LockId,
-	)
+		AND lock_id = $2
+		RETURNING ` + transferColumns
+
+	retried, err := scanTransfer(t.db.QueryRow(t.ctx, sql, transfer.TransferId, transfer.LockId, nextAttemptAt, lastError))
 
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, fmt.Errorf("transfer already locked or not found")
+		return nil, ErrLockReclaimed
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &updatedTransfer, nil
+	return retried, nil
 }
 
-func (t *TransferRepository) GetTransfer(transferId uuid.UUID) (*model.Transfer, error) {
+// CancelTransfer cancels the transfer if it is unsent and not locked by an outbox processor, releases the funds held
+// for it and records the transfer cancelled event - it returns nil if the transfer cannot be cancelled
+func (t *PostgresTransferRepository) CancelTransfer(transferId uuid.UUID) (cancelled *model.Transfer, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || cancelled == nil {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
+	// the lock_id check races safely with ClaimUnsentTransfers - whichever update commits first wins the row
 	sql := `
-		SELECT * FROM outgoing_transfer
-		WHERE transfer_id = $1`
+		UPDATE outgoing_transfer
+		SET status = $2
+		WHERE transfer_id = $1
+		AND status = $3
+		AND lock_id IS NULL
+		RETURNING ` + transferColumns
 
-	row := t.db.QueryRow(t.ctx, sql, model.UnsentTransferStatus, transferId)
+	cancelled, err = scanTransfer(tx.QueryRow(t.ctx, sql, transferId, model.CancelledTransferStatus, model.UnsentTransferStatus))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
 
-	var transfer model.Transfer
+	hold, err := getActiveHold(t.ctx, tx, transferId)
+	if err != nil {
+		return nil, err
+	}
 
-	err := row.Scan(&transfer.TransferId, &transfer.CreatedAt, &transfer.SentAt,
-		&transfer.FromAsset, &transfer.ToAsset,
-		&transfer.RequestedAmount, &transfer.Fee, &transfer.NetAmount,
-		&transfer.Rate, &transfer.SentAmount, &transfer.Sender, &transfer.Recipient,
-		&transfer.TransferStatus, &transfer.FailureReason, &transfer.TransferType,
-		&transfer.LockId)
+	if hold != nil {
+		if err = updateHold(t.ctx, tx, *hold, model.ReleasedHoldStatus); err != nil {
+			return nil, err
+		}
+	}
 
+	cancelledEvent, err := event.NewTransferCancelled(*cancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, cancelledEvent); err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+// CompleteTransfer marks a sent transfer as completed once the rail confirmed it, and records the transfer completed
+// event in the same transaction - it returns nil if the transfer is not (or no longer) sent
+func (t *PostgresTransferRepository) CompleteTransfer(transferId uuid.UUID) (completed *model.Transfer, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || completed == nil {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
+	sql := `
+		UPDATE outgoing_transfer
+		SET status = $2, settled_at = NOW()
+		WHERE transfer_id = $1
+		AND status = $3
+		RETURNING ` + transferColumns
+
+	completed, err = scanTransfer(tx.QueryRow(t.ctx, sql, transferId, model.CompletedTransferStatus, model.SentTransferStatus))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -136,42 +351,368 @@ func (t *TransferRepository) GetTransfer(transferId uuid.UUID) (*model.Transfer,
 		}
 	}
 
-	return &transfer, nil
+	completedEvent, err := event.NewTransferCompleted(*completed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, completedEvent); err != nil {
+		return nil, err
+	}
+
+	return completed, nil
 }
 
-func (t *TransferRepository) GetUnsentTransfers(limit int) ([]model.Transfer, error) {
+// ReverseTransfer marks the transfer as failed, reverses its ledger entries and records the transfer failed and
+// reversed events in the same transaction, if the transfer is in one of the given statuses - it returns nil if the
+// transfer is not (or no longer) in those statuses
+func (t *PostgresTransferRepository) ReverseTransfer(transferId uuid.UUID, reason string, statuses ...model.TransferStatus) (reversal *model.Reversal, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil || reversal == nil {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
 	sql := `
-		SELECT * FROM outgoing_transfer
+		UPDATE outgoing_transfer
+		SET status = $2, failure_reason = $3, settled_at = NOW()
+		WHERE transfer_id = $1
+		AND status::text = ANY($4::text[])
+		RETURNING ` + transferColumns
+
+	fromStatuses := make([]string, len(statuses))
+	for i, status := range statuses {
+		fromStatuses[i] = string(status)
+	}
+
+	failed, err := scanTransfer(tx.QueryRow(t.ctx, sql, transferId, model.FailedTransferStatus, reason, fromStatuses))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	entries, err := reverseLedgerEntries(t.ctx, tx, transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal = &model.Reversal{
+		Transfer: *failed,
+		Reason:   reason,
+		Entries:  entries,
+	}
+
+	failedEvent, err := event.NewTransferFailed(*failed)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*event.BaseEvent{failedEvent}
+
+	// a transfer that never reached the ledger only fails, there is nothing to reverse
+	if len(entries) > 0 {
+		reversedEvent, err := event.NewTransferReversed(*reversal)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, reversedEvent)
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, events...); err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+// GetUnsettledTransfers returns the transfers that were sent before sentBefore and were not settled since, oldest first
+func (t *PostgresTransferRepository) GetUnsettledTransfers(sentBefore time.Time, limit int) ([]model.Transfer, error) {
+	sql := `
+		SELECT ` + transferColumns + ` FROM outgoing_transfer
+		WHERE status = $1
+		AND sent_at < $2
+		ORDER BY sent_at LIMIT $3`
+
+	rows, err := t.db.Query(t.ctx, sql, model.SentTransferStatus, sentBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []model.Transfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		transfers = append(transfers, *transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// GetTransfer returns the transfer from the outbox, or nil if the transfer is not in the outbox (yet)
+func (t *PostgresTransferRepository) GetTransfer(transferId uuid.UUID) (*model.Transfer, error) {
+	sql := `
+		SELECT ` + transferColumns + ` FROM outgoing_transfer
+		WHERE transfer_id = $1`
+
+	transfer, err := scanTransfer(t.db.QueryRow(t.ctx, sql, transferId))
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	return transfer, nil
+}
+
+// GetUnsentTransferAssets returns the destination assets of the unsent transfers that are due for an attempt and not
+// being processed
+func (t *PostgresTransferRepository) GetUnsentTransferAssets() ([]string, error) {
+	sql := `
+		SELECT DISTINCT to_asset FROM outgoing_transfer
 		WHERE status = $1
 		AND lock_id IS NULL
-		ORDER BY created_at LIMIT $2`
+		AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())`
 
-	rows, err := t.db.Query(t.ctx, sql, model.UnsentTransferStatus, limit)
+	rows, err := t.db.Query(t.ctx, sql, model.UnsentTransferStatus)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	if rows.Err() != nil {
-		return nil, rows.Err()
+	var assets []string
+	for rows.Next() {
+		var asset string
+		if err := rows.Scan(&asset); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		assets = append(assets, asset)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
+	return assets, nil
+}
+
+// GetExpiredLocks returns the transfers whose outbox processor lock expired, oldest first
+func (t *PostgresTransferRepository) GetExpiredLocks(limit int) ([]model.Transfer, error) {
+	sql := `
+		SELECT ` + transferColumns + ` FROM outgoing_transfer
+		WHERE lock_id IS NOT NULL
+		AND lock_expires_at < NOW()
+		ORDER BY lock_expires_at LIMIT $1`
+
+	rows, err := t.db.Query(t.ctx, sql, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	var transfers []model.Transfer
 	for rows.Next() {
-		var transfer model.Transfer
-		err := rows.Scan(
-			&transfer.TransferId, &transfer.CreatedAt, &transfer.SentAt,
-			&transfer.FromAsset, &transfer.ToAsset,
-			&transfer.RequestedAmount, &transfer.Fee, &transfer.NetAmount,
-			&transfer.Rate, &transfer.SentAmount, &transfer.Sender, &transfer.Recipient,
-			&transfer.TransferStatus, &transfer.FailureReason, &transfer.TransferType,
-			&transfer.LockId)
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		transfers = append(transfers, *transfer)
+	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// ReclaimLock takes back the expired lock of the transfer. If the ledger entries of the transfer were committed, the
+// processor died after applying the transfer, so the transfer is recorded as sent with its transfer sent event -
+// otherwise the lock is released, and the transfer is processed again.
+func (t *PostgresTransferRepository) ReclaimLock(transfer model.Transfer) (reclaim model.LockReclaim, recovered *model.Transfer, err error) {
+	tx, err := t.db.Begin(t.ctx)
+	if err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	defer func() {
+		if err != nil || reclaim == model.NoLockReclaim {
+			_ = tx.Rollback(t.ctx)
+			return
+		}
+
+		err = tx.Commit(t.ctx)
+	}()
+
+	// waits for a processor still holding the row, and skips the transfer if the lock was released or renewed meanwhile
+	sql := `
+		SELECT ` + transferColumns + ` FROM outgoing_transfer
+		WHERE transfer_id = $1
+		AND lock_id = $2
+		AND lock_expires_at < NOW()
+		FOR UPDATE`
+
+	locked, err := scanTransfer(tx.QueryRow(t.ctx, sql, transfer.TransferId, transfer.LockId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.NoLockReclaim, nil, nil
+		} else {
+			return model.NoLockReclaim, nil, err
+		}
+	}
+
+	var sentAt *time.Time
+	var sentAmount *decimal.Decimal
+
+	sql = `
+		SELECT MIN(created_at), SUM(amount) FILTER (WHERE account = $2 AND asset = $3 AND ledger_entry_type = $4)
+		FROM ledger_history
+		WHERE transfer_id = $1`
+
+	err = tx.QueryRow(t.ctx, sql, locked.TransferId, locked.Recipient, locked.ToAsset, model.TransferLedgerEntryType).Scan(&sentAt, &sentAmount)
+	if err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	if sentAt == nil {
+		sql = `
+			UPDATE outgoing_transfer
+			SET lock_id = NULL, locked_at = NULL, lock_expires_at = NULL
+			WHERE transfer_id = $1`
+
+		if _, err = tx.Exec(t.ctx, sql, locked.TransferId); err != nil {
+			return model.NoLockReclaim, nil, err
+		}
+
+		return model.ReleasedLockReclaim, nil, nil
+	}
+
+	locked.TransferStatus = model.SentTransferStatus
+	locked.SentAt = sentAt
+	locked.SentAmount = sentAmount
+
+	// internal transfers are completed once sent, there is no rail to confirm them
+	if locked.TransferType == model.InternalTransferType {
+		locked.TransferStatus = model.CompletedTransferStatus
+		locked.SettledAt = sentAt
+	}
+
+	recovered, err = unlockAndUpdateTransfer(t.ctx, tx, *locked)
+	if err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	sentEvent, err := event.NewTransferSent(*recovered)
+	if err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	if err = insertOutboxEvents(t.ctx, tx, sentEvent); err != nil {
+		return model.NoLockReclaim, nil, err
+	}
+
+	return model.RecoveredLockReclaim, recovered, nil
+}
+
+// scanTransfer scans a row selected with transferColumns
+func scanTransfer(row pgx.Row) (*model.Transfer, error) {
+	var transfer model.Transfer
+
+	err := row.Scan(&transfer.TransferId, &transfer.CreatedAt, &transfer.SentAt,
+		&transfer.FromAsset, &transfer.ToAsset,
+		&transfer.RequestedAmount, &transfer.Fee, &transfer.NetAmount,
+		&transfer.Rate, &transfer.SentAmount, &transfer.Sender, &transfer.Recipient,
+		&transfer.TransferStatus, &transfer.FailureReason, &transfer.TransferType,
+		&transfer.LockId, &transfer.IdempotencyKey, &transfer.SettledAt,
+		&transfer.LockedAt, &transfer.LockExpiresAt, &transfer.AttemptCount, &transfer.NextAttemptAt, &transfer.LastError)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// ListAccountTransfers returns the transfers matching the filter, most recent first
+func (t *PostgresTransferRepository) ListAccountTransfers(filter model.TransferFilter) ([]model.Transfer, error) {
+	sql := `
+		SELECT ` + transferColumns + ` FROM outgoing_transfer
+		WHERE (sender = $1 OR recipient = $1)`
+
+	args := []any{filter.Account}
+	addCondition := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		sql += "\n\t\tAND " + condition
+	}
+
+	if filter.Status != nil {
+		addCondition("status = ?", *filter.Status)
+	}
+
+	if filter.Asset != nil {
+		addCondition("(from_asset = ? OR to_asset = ?)", *filter.Asset, *filter.Asset)
+	}
+
+	if filter.TransferType != nil {
+		addCondition("transfer_type = ?", *filter.TransferType)
+	}
+
+	if filter.CreatedFrom != nil {
+		addCondition("created_at >= ?", *filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		addCondition("created_at < ?", *filter.CreatedTo)
+	}
+
+	if filter.After != nil {
+		addCondition("(created_at, transfer_id) < (?, ?)", filter.After.CreatedAt, filter.After.TransferId)
+	}
+
+	args = append(args, filter.Limit)
+	sql += fmt.Sprintf("\n\t\tORDER BY created_at DESC, transfer_id DESC LIMIT $%d", len(args))
+
+	rows, err := t.db.Query(t.ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []model.Transfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		transfers = append(transfers, transfer)
+		transfers = append(transfers, *transfer)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -1,3 +1,14 @@
 package services
 
 const TransferTopic = "sphere-transfer-events"
+
+// DeadLetterTopic receives the messages of TransferTopic a consumer failed to decode or process
+const DeadLetterTopic = "sphere-transfer-events-dlq"
+
+// consumer groups of the services consuming the event bus
+const (
+	TransferServiceConsumer        = "sphere-transfer-service-consumer"
+	TransferHistoryServiceConsumer = "sphere-transfer-history-service-consumer"
+	SettlementServiceConsumer      = "sphere-settlement-service-consumer"
+	DeadLetterServiceConsumer      = "sphere-dead-letter-service-consumer"
+)
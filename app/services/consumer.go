@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sphere-homework/app/eventbus"
+	"time"
+)
+
+// consumerPollTimeout bounds how long a consumer blocks reading a message, so it notices when it is stopped
+const consumerPollTimeout = time.Second
+
+// consumerRetryDelay is how long a consumer waits before it is redelivered a message it could neither handle nor
+// dead-letter, or before it reads again after a read failed
+var consumerRetryDelay = 5 * time.Second
+
+// consumeMessages hands the messages of the subscribed topics to handle, and commits a message once it was handled or
+// dead-lettered - a message is redelivered until then. A message that failed because the database or network was
+// unavailable, or could not be dead-lettered, rewinds its partition, which keeps the order of the messages of a
+// partition. Messages are not dead-lettered if deadLetterService is nil.
+func consumeMessages(ctx context.Context, subscriber eventbus.Subscriber, logger *zap.Logger, name string,
+	deadLetterService *DeadLetterService, handle func(eventbus.Message) error) {
+
+	for ctx.Err() == nil {
+		msg, err := subscriber.Read(consumerPollTimeout)
+		if err != nil {
+			logger.Error("Error reading message from consumer", zap.Error(err))
+
+			// e.g. the brokers are down, reading again right away would spin
+			waitRetryDelay(ctx)
+			continue
+		}
+
+		if msg == nil {
+			continue
+		}
+
+		err = handle(*msg)
+		if err != nil {
+			logger.Error("Unable to handle the event", zap.String("position", msg.Position()), zap.Error(err))
+
+			// the message is fine, but e.g. the database is down - it is retried rather than dead-lettered
+			if deadLetterService == nil || isUnavailableError(err) {
+				rewind(ctx, subscriber, logger, *msg)
+				continue
+			}
+
+			if errDeadLetter := deadLetterService.Publish(name, *msg, err); errDeadLetter != nil {
+				logger.Error("Unable to publish dead letter", zap.Error(errDeadLetter))
+				rewind(ctx, subscriber, logger, *msg)
+				continue
+			}
+		}
+
+		if err = subscriber.Commit(*msg); err != nil {
+			// the message is redelivered after a rebalance or restart, which the handlers tolerate
+			logger.Error("Unable to commit offset", zap.String("position", msg.Position()), zap.Error(err))
+		}
+	}
+}
+
+// rewind seeks the partition back to the message, so it is read again after the retry delay
+func rewind(ctx context.Context, subscriber eventbus.Subscriber, logger *zap.Logger, msg eventbus.Message) {
+	if err := subscriber.Rewind(msg); err != nil {
+		logger.Error("Unable to rewind partition", zap.String("position", msg.Position()), zap.Error(err))
+	}
+
+	waitRetryDelay(ctx)
+}
+
+// waitRetryDelay waits for consumerRetryDelay, or until the consumer is stopped
+func waitRetryDelay(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(consumerRetryDelay):
+	}
+}
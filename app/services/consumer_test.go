@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sphere-homework/app/eventbus"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// consume runs consumeMessages until count messages were handled
+func consume(t *testing.T, bus *eventbus.MemoryBus, deadLetterService *DeadLetterService, count int, handle func(eventbus.Message) error) []string {
+	subscriber := bus.Subscriber(TransferServiceConsumer)
+	assert.NoError(t, subscriber.Subscribe(TransferTopic))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan string, count)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		consumeMessages(ctx, subscriber, zap.NewNop(), TransferServiceConsumer, deadLetterService, func(msg eventbus.Message) error {
+			handled <- string(msg.Value)
+			return handle(msg)
+		})
+	}()
+
+	var values []string
+	for len(values) < count {
+		select {
+		case value := <-handled:
+			values = append(values, value)
+		case <-time.After(time.Second):
+			t.Fatalf("handled %v, expected %d messages", values, count)
+		}
+	}
+
+	cancel()
+	assert.NoError(t, subscriber.Close())
+	<-done
+
+	return values
+}
+
+func TestConsumeMessagesDeadLettersFailedMessages(t *testing.T) {
+	bus := eventbus.NewMemoryBus()
+	for _, value := range []string{"a", "b", "c"} {
+		assert.NoError(t, bus.Publish(eventbus.Message{Topic: TransferTopic, Value: []byte(value)}))
+	}
+
+	deadLetterService := NewDeadLetterService(bus, nil, zap.NewNop(), nil, context.Background())
+
+	handled := consume(t, bus, deadLetterService, 3, func(msg eventbus.Message) error {
+		if string(msg.Value) == "b" {
+			return errors.New("invalid event")
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, handled)
+
+	deadLetters := bus.Messages(DeadLetterTopic)
+	assert.Len(t, deadLetters, 1)
+
+	deadLetter, err := toDeadLetter(deadLetters[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(deadLetter.Payload))
+	assert.Equal(t, "invalid event", deadLetter.Error)
+	assert.Equal(t, int64(1), deadLetter.Offset)
+
+	// every message was committed, including the dead-lettered one
+	restarted := bus.Subscriber(TransferServiceConsumer)
+	assert.NoError(t, restarted.Subscribe(TransferTopic))
+
+	msg, err := restarted.Read(10 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestConsumeMessagesRetriesUnavailableErrorsInOrder(t *testing.T) {
+	retryDelay := consumerRetryDelay
+	consumerRetryDelay = time.Millisecond
+	defer func() { consumerRetryDelay = retryDelay }()
+
+	bus := eventbus.NewMemoryBus()
+	for _, value := range []string{"a", "b", "c"} {
+		assert.NoError(t, bus.Publish(eventbus.Message{Topic: TransferTopic, Value: []byte(value)}))
+	}
+
+	deadLetterService := NewDeadLetterService(bus, nil, zap.NewNop(), nil, context.Background())
+
+	failed := false
+	handled := consume(t, bus, deadLetterService, 4, func(msg eventbus.Message) error {
+		if string(msg.Value) == "b" && !failed {
+			failed = true
+			return context.DeadlineExceeded
+		}
+		return nil
+	})
+
+	assert.Equal(t, []string{"a", "b", "b", "c"}, handled)
+	assert.Empty(t, bus.Messages(DeadLetterTopic))
+}
+
+// failingSubscriber fails every read, like a consumer whose brokers are down
+type failingSubscriber struct {
+	eventbus.Subscriber
+	reads atomic.Int32
+}
+
+func (s *failingSubscriber) Read(time.Duration) (*eventbus.Message, error) {
+	s.reads.Add(1)
+	return nil, errors.New("all brokers are down")
+}
+
+func TestConsumeMessagesWaitsAfterReadErrors(t *testing.T) {
+	retryDelay := consumerRetryDelay
+	consumerRetryDelay = 20 * time.Millisecond
+	defer func() { consumerRetryDelay = retryDelay }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	subscriber := &failingSubscriber{}
+	consumeMessages(ctx, subscriber, zap.NewNop(), TransferServiceConsumer, nil, func(eventbus.Message) error {
+		return nil
+	})
+
+	assert.LessOrEqual(t, subscriber.reads.Load(), int32(3))
+}
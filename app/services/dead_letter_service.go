@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"strconv"
+	"strings"
+)
+
+// headers the dead-letter metadata is sent with, next to the headers of the original message
+const (
+	deadLetterHeaderPrefix    = "dlq."
+	deadLetterConsumerHeader  = deadLetterHeaderPrefix + "consumer"
+	deadLetterErrorHeader     = deadLetterHeaderPrefix + "error"
+	deadLetterTopicHeader     = deadLetterHeaderPrefix + "topic"
+	deadLetterPartitionHeader = deadLetterHeaderPrefix + "partition"
+	deadLetterOffsetHeader    = deadLetterHeaderPrefix + "offset"
+)
+
+// DeadLetterService is responsible for:
+// 1. Publishing the messages consumers failed to decode or process to the dead-letter topic
+// 2. Listening to the dead-letter topic and recording the dead letters, so they can be listed
+// 3. Replaying dead letters onto the transfer topic on demand, e.g. once the bug that failed them is fixed
+type DeadLetterService struct {
+	*lifecycle
+	publisher  eventbus.Publisher
+	subscriber eventbus.Subscriber
+	logger     *zap.Logger
+	repository repository.DeadLetterRepository
+}
+
+func NewDeadLetterService(publisher eventbus.Publisher, subscriber eventbus.Subscriber, logger *zap.Logger,
+	repository repository.DeadLetterRepository, ctx context.Context) *DeadLetterService {
+
+	return &DeadLetterService{
+		lifecycle:  newLifecycle(ctx, "dead letter service"),
+		publisher:  publisher,
+		subscriber: subscriber,
+		logger:     logger,
+		repository: repository,
+	}
+}
+
+func (d *DeadLetterService) Init() error {
+	err := d.subscriber.Subscribe(DeadLetterTopic)
+	if err != nil {
+		return err
+	}
+
+	// this go-routine listens to kafka for dead letters - and writes them to the dead_letter table
+	d.run(func() {
+		d.logger.Info("Starting dead letter service consumer")
+		consumeMessages(d.ctx, d.subscriber, d.logger, DeadLetterServiceConsumer, nil, d.handleMessage)
+	})
+
+	return nil
+}
+
+// Stop stops recording dead letters, and closes the subscriber once its go-routines finished
+func (d *DeadLetterService) Stop(ctx context.Context) error {
+	if err := d.lifecycle.Stop(ctx); err != nil {
+		return err
+	}
+
+	return d.subscriber.Close()
+}
+
+func (d *DeadLetterService) handleMessage(msg eventbus.Message) error {
+	deadLetter, err := toDeadLetter(msg)
+	if err != nil {
+		// a message that is not a dead letter is skipped, it is never going to decode
+		d.logger.Error("Unable to decode dead letter", zap.String("position", msg.Position()), zap.Error(err))
+		return nil
+	}
+
+	return d.repository.InsertDeadLetter(deadLetter)
+}
+
+// Publish sends a message the consumer failed to handle to the dead-letter topic, with its original key, payload and
+// headers - it returns once the broker acknowledged the dead letter
+func (d *DeadLetterService) Publish(consumer string, msg eventbus.Message, cause error) error {
+	d.logger.Info("Publishing dead letter",
+		zap.String("consumer", consumer),
+		zap.String("position", msg.Position()),
+		zap.Error(cause))
+
+	return d.publisher.Publish(deadLetterMessage(consumer, msg, cause))
+}
+
+// Replay publishes the dead letter back onto the transfer topic and marks it replayed - it returns nil if there is no
+// such dead letter, and repository.ErrDeadLetterReplayed if it was already replayed
+func (d *DeadLetterService) Replay(id int64) (*model.DeadLetter, error) {
+	return d.repository.ReplayDeadLetter(id, func(deadLetter model.DeadLetter) error {
+		d.logger.Info("Replaying dead letter", zap.Int64("id", deadLetter.Id), zap.String("consumer", deadLetter.Consumer))
+
+		msg := eventbus.Message{
+			Topic: TransferTopic,
+			Key:   deadLetter.Key,
+			Value: deadLetter.Payload,
+		}
+
+		for _, header := range deadLetter.Headers {
+			msg.Headers = append(msg.Headers, eventbus.Header{Key: header.Key, Value: header.Value})
+		}
+
+		return d.publisher.Publish(msg)
+	})
+}
+
+func deadLetterMessage(consumer string, msg eventbus.Message, cause error) eventbus.Message {
+	deadLetter := eventbus.Message{
+		Topic: DeadLetterTopic,
+		Key:   msg.Key,
+		Value: msg.Value,
+	}
+
+	// a replayed message that fails again is sent with its latest dead-letter metadata only
+	for _, header := range msg.Headers {
+		if !strings.HasPrefix(header.Key, deadLetterHeaderPrefix) {
+			deadLetter.Headers = append(deadLetter.Headers, header)
+		}
+	}
+
+	deadLetter.Headers = append(deadLetter.Headers,
+		eventbus.Header{Key: deadLetterConsumerHeader, Value: []byte(consumer)},
+		eventbus.Header{Key: deadLetterErrorHeader, Value: []byte(cause.Error())},
+		eventbus.Header{Key: deadLetterTopicHeader, Value: []byte(msg.Topic)},
+		eventbus.Header{Key: deadLetterPartitionHeader, Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		eventbus.Header{Key: deadLetterOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	return deadLetter
+}
+
+// toDeadLetter splits the dead-letter metadata from the headers of the original message
+func toDeadLetter(msg eventbus.Message) (model.DeadLetter, error) {
+	deadLetter := model.DeadLetter{
+		Key:     msg.Key,
+		Payload: msg.Value,
+		Headers: []model.DeadLetterHeader{},
+	}
+
+	for _, header := range msg.Headers {
+		var err error
+
+		switch header.Key {
+		case deadLetterConsumerHeader:
+			deadLetter.Consumer = string(header.Value)
+		case deadLetterErrorHeader:
+			deadLetter.Error = string(header.Value)
+		case deadLetterTopicHeader:
+			deadLetter.Topic = string(header.Value)
+		case deadLetterPartitionHeader:
+			var partition int64
+			partition, err = strconv.ParseInt(string(header.Value), 10, 32)
+			deadLetter.Partition = int32(partition)
+		case deadLetterOffsetHeader:
+			deadLetter.Offset, err = strconv.ParseInt(string(header.Value), 10, 64)
+		default:
+			deadLetter.Headers = append(deadLetter.Headers, model.DeadLetterHeader{Key: header.Key, Value: header.Value})
+		}
+
+		if err != nil {
+			return model.DeadLetter{}, fmt.Errorf("invalid %s header: %w", header.Key, err)
+		}
+	}
+
+	if deadLetter.Consumer == "" || deadLetter.Topic == "" {
+		return model.DeadLetter{}, fmt.Errorf("message is missing the dead letter headers")
+	}
+
+	return deadLetter, nil
+}
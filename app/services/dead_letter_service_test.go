@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"testing"
+)
+
+func TestDeadLetterMessageRoundTrip(t *testing.T) {
+	msg := eventbus.Message{
+		Topic:     TransferTopic,
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("jim"),
+		Value:     []byte("{not json"),
+		Headers:   []eventbus.Header{{Key: "trace-id", Value: []byte("abc")}},
+	}
+
+	deadLetter, err := toDeadLetter(deadLetterMessage(TransferServiceConsumer, msg, errors.New("invalid character")))
+	assert.NoError(t, err)
+
+	assert.Equal(t, TransferServiceConsumer, deadLetter.Consumer)
+	assert.Equal(t, "invalid character", deadLetter.Error)
+	assert.Equal(t, TransferTopic, deadLetter.Topic)
+	assert.Equal(t, int32(3), deadLetter.Partition)
+	assert.Equal(t, int64(42), deadLetter.Offset)
+	assert.Equal(t, []byte("jim"), deadLetter.Key)
+	assert.Equal(t, []byte("{not json"), deadLetter.Payload)
+	assert.Equal(t, []model.DeadLetterHeader{{Key: "trace-id", Value: []byte("abc")}}, deadLetter.Headers)
+}
+
+func TestDeadLetterMessageReplacesPreviousMetadata(t *testing.T) {
+	msg := eventbus.Message{
+		Topic:   TransferTopic,
+		Offset:  7,
+		Value:   []byte("{}"),
+		Headers: []eventbus.Header{{Key: deadLetterErrorHeader, Value: []byte("first failure")}},
+	}
+
+	dlq := deadLetterMessage(TransferHistoryServiceConsumer, msg, errors.New("second failure"))
+
+	var errorHeaders []string
+	for _, header := range dlq.Headers {
+		if header.Key == deadLetterErrorHeader {
+			errorHeaders = append(errorHeaders, string(header.Value))
+		}
+	}
+
+	assert.Equal(t, []string{"second failure"}, errorHeaders)
+}
+
+func TestToDeadLetterRequiresMetadata(t *testing.T) {
+	_, err := toDeadLetter(eventbus.Message{Value: []byte("{}")})
+	assert.Error(t, err)
+}
+
+func TestDeadLetterServiceRecordsAndReplaysDeadLetters(t *testing.T) {
+	bus := eventbus.NewMemoryBus()
+	deadLetters := repository.NewMemoryDeadLetterRepository(repository.NewMemoryStore())
+	service := NewDeadLetterService(bus, nil, zap.NewNop(), deadLetters, context.Background())
+
+	msg := eventbus.Message{Topic: TransferTopic, Offset: 7, Key: []byte("jim"), Value: []byte("{not json")}
+	deadLetter := deadLetterMessage(TransferServiceConsumer, msg, errors.New("invalid character"))
+
+	// a dead letter consumed again is recorded once
+	assert.NoError(t, service.handleMessage(deadLetter))
+	assert.NoError(t, service.handleMessage(deadLetter))
+
+	replayed := false
+	pending, err := deadLetters.ListDeadLetters(model.DeadLetterFilter{Replayed: &replayed, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	replay, err := service.Replay(pending[0].Id)
+	assert.NoError(t, err)
+	assert.NotNil(t, replay.ReplayedAt)
+
+	messages := bus.Messages(TransferTopic)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, []byte("jim"), messages[0].Key)
+	assert.Equal(t, []byte("{not json"), messages[0].Value)
+
+	_, err = service.Replay(pending[0].Id)
+	assert.ErrorIs(t, err, repository.ErrDeadLetterReplayed)
+
+	missing, err := service.Replay(42)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sphere-homework/app/config"
+	eventModel "sphere-homework/app/event"
+	"sphere-homework/app/repository"
+	"time"
+)
+
+// EventRelayService publishes the events recorded in the event outbox to the event bus, in the order they were recorded
+type EventRelayService struct {
+	*lifecycle
+	logger                *zap.Logger
+	eventOutboxRepository repository.EventOutboxRepository
+	eventService          *EventService
+	config                config.Config
+}
+
+func NewEventRelayService(logger *zap.Logger, ctx context.Context, eventOutboxRepository repository.EventOutboxRepository,
+	eventService *EventService, config config.Config) *EventRelayService {
+
+	return &EventRelayService{
+		lifecycle:             newLifecycle(ctx, "event relay"),
+		logger:                logger,
+		eventOutboxRepository: eventOutboxRepository,
+		eventService:          eventService,
+		config:                config,
+	}
+}
+
+func (e *EventRelayService) Init() error {
+	// this go-routine polls the event outbox for unpublished events, and publishes them
+	e.run(func() {
+		e.logger.Info("Starting event relay")
+
+		ticker := time.NewTicker(time.Duration(e.config.EventRelayPollFrequencyMs) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.ctx.Done():
+				// the relay is stopped after the services writing to the outbox, so this publishes their last events
+				e.relay()
+				e.logger.Info("Shutting down event relay")
+				return
+			case <-ticker.C:
+				e.relay()
+			}
+		}
+	})
+
+	return nil
+}
+
+// relay publishes outbox events until the outbox is drained or an event fails to publish - events are claimed for twice
+// the delivery timeout, which bounds how long a publish takes
+func (e *EventRelayService) relay() {
+	lease := 2 * time.Duration(e.config.KafkaDeliveryTimeoutMs) * time.Millisecond
+
+	for {
+		relayed, err := e.eventOutboxRepository.RelayEvents(250, lease, func(event eventModel.BaseEvent) error {
+			err := e.eventService.PublishEvent(event)
+			if err != nil {
+				e.logger.Error("Unable to publish outbox event", zap.String("event_type", event.EventType), zap.Error(err))
+			}
+
+			return err
+		})
+
+		if err != nil {
+			e.logger.Error("Unable to relay outbox events", zap.Error(err))
+			return
+		}
+
+		if relayed < 250 {
+			return
+		}
+	}
+}
@@ -2,35 +2,51 @@ package services
 
 import (
 	"encoding/json"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
 )
 
 type EventService struct {
-	producer *kafka.Producer
+	publisher eventbus.Publisher
 }
 
-func NewEventService(producer *kafka.Producer) EventService {
+func NewEventService(publisher eventbus.Publisher) EventService {
 	return EventService{
-		producer: producer,
+		publisher: publisher,
 	}
 }
 
+// PublishEvent publishes the event to the transfer topic, and returns once the bus acknowledged it - it returns
+// eventbus.ErrPublishTimeout if the delivery could not be confirmed in time
 func (e *EventService) PublishEvent(event event.BaseEvent) error {
-	value, err := json.Marshal(event)
+	msg, err := eventMessage(event)
+	if err != nil {
+		return err
+	}
+
+	return e.publisher.Publish(msg)
+}
+
+// PublishEventAsync hands the event to the bus without waiting for its delivery, callback is called with the delivery
+// error, nil if delivered - it returns an error if the bus did not accept the event, in which case callback is not called
+func (e *EventService) PublishEventAsync(event event.BaseEvent, callback func(error)) error {
+	msg, err := eventMessage(event)
 	if err != nil {
 		return err
 	}
 
-	topic := TransferTopic
-	err = e.producer.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{
-			Topic:     &topic,
-			Partition: kafka.PartitionAny,
-		},
+	return e.publisher.PublishAsync(msg, callback)
+}
+
+func eventMessage(event event.BaseEvent) (eventbus.Message, error) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return eventbus.Message{}, err
+	}
+
+	return eventbus.Message{
+		Topic: TransferTopic,
 		Value: value,
 		Key:   []byte(event.Sender),
-	}, nil)
-
-	return err
+	}, nil
 }
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Service is a background component of the transfer service - it is initialized on startup, and stopped on shutdown in
+// the reverse order
+type Service interface {
+	Name() string
+	Init() error
+	// Stop stops the service and waits for its go-routines to finish, or for ctx to be done
+	Stop(ctx context.Context) error
+	// Health returns an error if the service is not running
+	Health() error
+}
+
+// lifecycle runs the go-routines of a service until it is stopped - services embed it, so their go-routines watch its
+// context and it implements Name, Stop and Health for them
+type lifecycle struct {
+	name     string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	routines sync.WaitGroup
+	running  atomic.Bool
+}
+
+func newLifecycle(ctx context.Context, name string) *lifecycle {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &lifecycle{
+		name:   name,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// run starts a go-routine that is waited for when the service stops
+func (l *lifecycle) run(routine func()) {
+	l.running.Store(true)
+	l.routines.Add(1)
+
+	go func() {
+		defer l.routines.Done()
+		routine()
+	}()
+}
+
+func (l *lifecycle) Name() string {
+	return l.name
+}
+
+func (l *lifecycle) Stop(ctx context.Context) error {
+	l.running.Store(false)
+	l.cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		l.routines.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s did not stop in time: %w", l.name, ctx.Err())
+	}
+}
+
+func (l *lifecycle) Health() error {
+	if !l.running.Load() {
+		return fmt.Errorf("%s is not running", l.name)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLifecycleStopWaitsForRoutines(t *testing.T) {
+	l := newLifecycle(context.Background(), "test service")
+	assert.Error(t, l.Health())
+
+	finished := false
+	l.run(func() {
+		<-l.ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		finished = true
+	})
+	assert.NoError(t, l.Health())
+
+	assert.NoError(t, l.Stop(context.Background()))
+	assert.True(t, finished)
+	assert.Error(t, l.Health())
+}
+
+func TestLifecycleStopTimesOut(t *testing.T) {
+	l := newLifecycle(context.Background(), "test service")
+
+	release := make(chan struct{})
+	defer close(release)
+
+	l.run(func() {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, l.Stop(ctx), context.DeadlineExceeded)
+}
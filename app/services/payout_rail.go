@@ -0,0 +1,44 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"sphere-homework/app/model"
+)
+
+// PayoutRail sends transfers out to the recipient, e.g. through a bank or payment provider
+type PayoutRail interface {
+	// Submit hands the transfer over to the rail - the returned payout is usually pending, and is settled later through
+	// a callback, a transfer settled event or by polling GetPayout. An error means the outcome of the submission is
+	// unknown, and the transfer is left to the settlement sweeper.
+	Submit(transfer model.Transfer) (*model.Payout, error)
+
+	// GetPayout returns the current state of the payout, or nil if the rail does not know the transfer
+	GetPayout(transferId uuid.UUID) (*model.Payout, error)
+}
+
+// PayoutRails selects the payout rail of a transfer by its destination asset - rails are registered at startup only
+type PayoutRails struct {
+	rails       map[string]PayoutRail
+	defaultRail PayoutRail
+}
+
+func NewPayoutRails(defaultRail PayoutRail) *PayoutRails {
+	return &PayoutRails{
+		rails:       map[string]PayoutRail{},
+		defaultRail: defaultRail,
+	}
+}
+
+func (p *PayoutRails) Register(asset string, rail PayoutRail) {
+	p.rails[asset] = rail
+}
+
+// Get returns the rail registered for the asset, or the default rail
+func (p *PayoutRails) Get(asset string) PayoutRail {
+	rail, ok := p.rails[asset]
+	if !ok {
+		return p.defaultRail
+	}
+
+	return rail
+}
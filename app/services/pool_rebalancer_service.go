@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"sphere-homework/app/config"
 	"sphere-homework/app/event"
@@ -13,38 +14,31 @@ import (
 )
 
 type PoolRebalancerService struct {
-	logger              *zap.Logger
-	ctx                 context.Context
-	config              config.Config
-	transferRepository  *repository.TransferRepository
-	ledgerRepository    *repository.LedgerRepository
-	poolBalancerSetting map[string]PoolReBalancerSetting
-	eventService        *EventService
-	rateRepository      *repository.RateRepository
+	*lifecycle
+	logger             *zap.Logger
+	config             config.Config
+	transferRepository repository.TransferRepository
+	ledgerRepository   repository.LedgerRepository
+	settingRepository  repository.PoolRebalanceSettingRepository
+	eventService       *EventService
+	rateRepository     repository.RateRepository
 }
 
-type PoolReBalancerSetting struct {
-	ImbalanceThreshold      float64 // if it exceeds the current threshold, it means we are experiencing some demand (either withdrawals for positive value, or deposits for negative value) and may need to trigger a re-balance if balance is also less than minimum balance
-	MinimumBalance          float64 // required minimum balance
-	TopUpAmount             float64 // the amount to be added to this pool if a re-balancing is needed
-	RequiredBalanceForTopUp float64 // the asset needs have this amount of balance before we transfer out balance from this asset
-}
-
-func NewPoolRebalancerService(logger *zap.Logger, ctx context.Context, rateRepository *repository.RateRepository, transferRepository *repository.TransferRepository, ledgerRepository *repository.LedgerRepository, eventService *EventService, config config.Config, poolBalancerSetting map[string]PoolReBalancerSetting) *PoolRebalancerService {
+func NewPoolRebalancerService(logger *zap.Logger, ctx context.Context, rateRepository repository.RateRepository, transferRepository repository.TransferRepository, ledgerRepository repository.LedgerRepository, eventService *EventService, config config.Config, settingRepository repository.PoolRebalanceSettingRepository) *PoolRebalancerService {
 	return &PoolRebalancerService{
-		logger:              logger,
-		ctx:                 ctx,
-		config:              config,
-		transferRepository:  transferRepository,
-		ledgerRepository:    ledgerRepository,
-		eventService:        eventService,
-		poolBalancerSetting: poolBalancerSetting,
-		rateRepository:      rateRepository,
+		lifecycle:          newLifecycle(ctx, "pool rebalancer service"),
+		logger:             logger,
+		config:             config,
+		transferRepository: transferRepository,
+		ledgerRepository:   ledgerRepository,
+		eventService:       eventService,
+		settingRepository:  settingRepository,
+		rateRepository:     rateRepository,
 	}
 }
 
-func (p *PoolRebalancerService) Init() {
-	go func() {
+func (p *PoolRebalancerService) Init() error {
+	p.run(func() {
 		p.logger.Info("Starting system pool rebalancer service")
 
 		ticker := time.NewTicker(time.Duration(p.config.PoolRebalancerPollFreqnecySec) * time.Second)
@@ -65,8 +59,9 @@ func (p *PoolRebalancerService) Init() {
 			}
 		}
 
-	}()
+	})
 
+	return nil
 }
 
 // algorithm:
@@ -81,6 +76,12 @@ func (p *PoolRebalancerService) checkSystemPool() error {
 		return err
 	}
 
+	// settings are loaded on every check, so changes to the pool_rebalance_setting table apply without a restart
+	settings, err := p.getSettings()
+	if err != nil {
+		return err
+	}
+
 	// This picks an asset pool that has the most incoming deposit ratio as the pool where we will use for funding the re-balancing
 	// todo - maybe add more criteria here, like imbalance ratio threshold
 	assetTrendingDeposit := getAssetDepositMostTrending(balances)
@@ -88,7 +89,7 @@ func (p *PoolRebalancerService) checkSystemPool() error {
 	p.logger.Info("Checking system pool balances", zap.Any("balances", balances))
 	for _, balance := range balances {
 		imbalanceRatio := balance.GetImbalanceRatio()
-		setting, ok := p.poolBalancerSetting[balance.Asset]
+		setting, ok := settings[balance.Asset]
 
 		if !ok {
 			continue
@@ -96,7 +97,7 @@ func (p *PoolRebalancerService) checkSystemPool() error {
 
 		// we need a re-balance here because the asset's loosing liquidity fast, and it is below the minimum required balance
 		// note: maybe even if we are not loosing liquidity fast but if below minimum balance, we should also trigger a re-balance?
-		if imbalanceRatio >= setting.ImbalanceThreshold && balance.Amount < setting.MinimumBalance {
+		if imbalanceRatio >= setting.ImbalanceThreshold && balance.Amount.LessThan(setting.MinimumBalance) {
 			if assetTrendingDeposit == nil {
 				p.logger.Info("Asset pool needs re-balancing but no source asset pool identified")
 				continue
@@ -107,18 +108,18 @@ func (p *PoolRebalancerService) checkSystemPool() error {
 				continue
 			}
 
-			requiredBalance, ok := p.poolBalancerSetting[assetTrendingDeposit.Asset]
+			requiredBalance, ok := settings[assetTrendingDeposit.Asset]
 			if !ok {
 				p.logger.Info("Unable to find required balance - not re-balancing", zap.Any("asset", assetTrendingDeposit))
 				continue
 			}
 
-			if assetTrendingDeposit.Amount < requiredBalance.RequiredBalanceForTopUp {
+			if assetTrendingDeposit.Amount.LessThan(requiredBalance.RequiredBalanceForTopUp) {
 				p.logger.Info("Source pool has less than required balance for re-balancing", zap.Any("asset", assetTrendingDeposit))
 				continue
 			}
 
-			return p.submitRebalancingTransaction(assetTrendingDeposit, balance.Asset)
+			return p.submitRebalancingTransaction(assetTrendingDeposit, balance.Asset, requiredBalance.TopUpAmount)
 		} else {
 			p.logger.Info("No need to re-balance asset", zap.Any("balance", balance), zap.Float64("imbalance_ratio", imbalanceRatio))
 		}
@@ -127,12 +128,25 @@ func (p *PoolRebalancerService) checkSystemPool() error {
 	return nil
 }
 
-func (p *PoolRebalancerService) submitRebalancingTransaction(fromAsset *model.LedgerBalance, toAsset string) error {
+// getSettings returns the rebalancer settings by asset
+func (p *PoolRebalancerService) getSettings() (map[string]model.PoolRebalanceSetting, error) {
+	settings, err := p.settingRepository.GetPoolRebalanceSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	byAsset := make(map[string]model.PoolRebalanceSetting)
+	for _, setting := range settings {
+		byAsset[setting.Asset] = setting
+	}
+
+	return byAsset, nil
+}
+
+func (p *PoolRebalancerService) submitRebalancingTransaction(fromAsset *model.LedgerBalance, toAsset string, topUpAmount decimal.Decimal) error {
 	p.logger.Info("Submitting transaction for re-balancing", zap.Any("source_asset", fromAsset), zap.Float64("imbalance_ratio", fromAsset.GetImbalanceRatio()))
 
 	// todo: if we already submitted a re-balancing transaction, don't re-submit it anymore
-	topUpAmount := p.poolBalancerSetting[fromAsset.Asset].TopUpAmount
-
 	payload := event.TransferCreated{
 		Transfer: event.Transfer{
 			TransferId: uuid.New(),
@@ -141,10 +155,10 @@ func (p *PoolRebalancerService) submitRebalancingTransaction(fromAsset *model.Le
 			Sender:     repository.SystemAccount,
 			Recipient:  repository.SystemAccount,
 			Amount:     topUpAmount,
-			Fee:        0,
-			Rate:       0,
+			Fee:        decimal.Zero,
+			Rate:       decimal.Zero,
 		},
-		Status: "transfer_created",
+		Status: event.CreatedTransferEventStatus,
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -154,7 +168,7 @@ func (p *PoolRebalancerService) submitRebalancingTransaction(fromAsset *model.Le
 
 	baseEvent := event.BaseEvent{
 		Timestamp: time.Now().UnixMilli(),
-		EventType: "transfer_created",
+		EventType: event.TransferCreatedEventType,
 		Sender:    repository.SystemAccount,
 		Payload:   payloadBytes,
 	}
@@ -1,8 +1,17 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sphere-homework/app/config"
+	"sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
 	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
 	"testing"
 )
 
@@ -10,21 +19,21 @@ func TestGetAssetDepositTrending(t *testing.T) {
 	balances := []model.LedgerBalance{
 		{
 			Asset:   "ETH",
-			Amount:  10000,
-			Inflow:  1000,
-			Outflow: 100,
+			Amount:  decimal.NewFromInt(10000),
+			Inflow:  decimal.NewFromInt(1000),
+			Outflow: decimal.NewFromInt(100),
 		},
 		{
 			Asset:   "BTC",
-			Amount:  6000,
-			Inflow:  10,
-			Outflow: 2000,
+			Amount:  decimal.NewFromInt(6000),
+			Inflow:  decimal.NewFromInt(10),
+			Outflow: decimal.NewFromInt(2000),
 		},
 		{
 			Asset:   "CELO",
-			Amount:  12000,
-			Inflow:  13000,
-			Outflow: 1000,
+			Amount:  decimal.NewFromInt(12000),
+			Inflow:  decimal.NewFromInt(13000),
+			Outflow: decimal.NewFromInt(1000),
 		},
 	}
 	balance := getAssetDepositMostTrending(balances)
@@ -36,21 +45,21 @@ func TestGetAssetDepositNoTrending1(t *testing.T) {
 	balances := []model.LedgerBalance{
 		{
 			Asset:   "ETH",
-			Amount:  10000,
-			Inflow:  0,
-			Outflow: 100,
+			Amount:  decimal.NewFromInt(10000),
+			Inflow:  decimal.NewFromInt(0),
+			Outflow: decimal.NewFromInt(100),
 		},
 		{
 			Asset:   "BTC",
-			Amount:  6000,
-			Inflow:  0,
-			Outflow: 2000,
+			Amount:  decimal.NewFromInt(6000),
+			Inflow:  decimal.NewFromInt(0),
+			Outflow: decimal.NewFromInt(2000),
 		},
 		{
 			Asset:   "CELO",
-			Amount:  12000,
-			Inflow:  0,
-			Outflow: 1000,
+			Amount:  decimal.NewFromInt(12000),
+			Inflow:  decimal.NewFromInt(0),
+			Outflow: decimal.NewFromInt(1000),
 		},
 	}
 	balance := getAssetDepositMostTrending(balances)
@@ -62,21 +71,21 @@ func TestGetAssetDepositNoTrending2(t *testing.T) {
 	balances := []model.LedgerBalance{
 		{
 			Asset:   "ETH",
-			Amount:  10000,
-			Inflow:  20,
-			Outflow: 100,
+			Amount:  decimal.NewFromInt(10000),
+			Inflow:  decimal.NewFromInt(20),
+			Outflow: decimal.NewFromInt(100),
 		},
 		{
 			Asset:   "BTC",
-			Amount:  6000,
-			Inflow:  30,
-			Outflow: 2000,
+			Amount:  decimal.NewFromInt(6000),
+			Inflow:  decimal.NewFromInt(30),
+			Outflow: decimal.NewFromInt(2000),
 		},
 		{
 			Asset:   "CELO",
-			Amount:  12000,
-			Inflow:  10,
-			Outflow: 1000,
+			Amount:  decimal.NewFromInt(12000),
+			Inflow:  decimal.NewFromInt(10),
+			Outflow: decimal.NewFromInt(1000),
 		},
 	}
 	balance := getAssetDepositMostTrending(balances)
@@ -88,12 +97,74 @@ func TestGetAssetDepositTrendingOneElement(t *testing.T) {
 	balances := []model.LedgerBalance{
 		{
 			Asset:   "ETH",
-			Amount:  10000,
-			Inflow:  200,
-			Outflow: 10,
+			Amount:  decimal.NewFromInt(10000),
+			Inflow:  decimal.NewFromInt(200),
+			Outflow: decimal.NewFromInt(10),
 		},
 	}
 	balance := getAssetDepositMostTrending(balances)
 
 	assert.Equal(t, "ETH", balance.Asset)
 }
+
+func TestCheckSystemPoolRebalancesFromDepositTrendingAsset(t *testing.T) {
+	r := newMemoryRepositories(t)
+
+	// USD is drained by withdrawals, EUR is filled by deposits
+	r.ledger.SetBalance(repository.SystemAccount, "USD", decimal.NewFromInt(5000))
+	r.ledger.SetBalance(repository.SystemAccount, "EUR", decimal.NewFromInt(50000))
+	r.ledger.ApplyLedgerEntries(
+		model.LedgerEntry{TransferId: uuid.New(), Account: repository.SystemAccount, Asset: "USD", Amount: decimal.NewFromInt(-3000), Type: model.TransferLedgerEntryType},
+		model.LedgerEntry{TransferId: uuid.New(), Account: repository.SystemAccount, Asset: "EUR", Amount: decimal.NewFromInt(10000), Type: model.TransferLedgerEntryType},
+	)
+
+	settings := repository.NewMemoryPoolRebalanceSettingRepository(r.store)
+	settings.SetPoolRebalanceSetting(model.PoolRebalanceSetting{Asset: "USD", ImbalanceThreshold: 0.5, MinimumBalance: decimal.NewFromInt(10000), TopUpAmount: decimal.NewFromInt(5000), RequiredBalanceForTopUp: decimal.NewFromInt(20000)})
+	settings.SetPoolRebalanceSetting(model.PoolRebalanceSetting{Asset: "EUR", ImbalanceThreshold: 0.5, MinimumBalance: decimal.NewFromInt(10000), TopUpAmount: decimal.NewFromInt(8000), RequiredBalanceForTopUp: decimal.NewFromInt(20000)})
+
+	bus := eventbus.NewMemoryBus()
+	eventService := NewEventService(bus)
+	service := NewPoolRebalancerService(zap.NewNop(), context.Background(), repository.NewMemoryRateRepository(r.store),
+		r.transfers, r.ledger, &eventService, config.Config{}, settings)
+
+	assert.NoError(t, service.checkSystemPool())
+
+	messages := bus.Messages(TransferTopic)
+	assert.Len(t, messages, 1)
+
+	var baseEvent event.BaseEvent
+	assert.NoError(t, json.Unmarshal(messages[0].Value, &baseEvent))
+	assert.Equal(t, event.TransferCreatedEventType, baseEvent.EventType)
+
+	var created event.TransferCreated
+	assert.NoError(t, json.Unmarshal(baseEvent.Payload, &created))
+	assert.Equal(t, "EUR", created.FromAsset)
+	assert.Equal(t, "USD", created.ToAsset)
+	assert.Equal(t, repository.SystemAccount, created.Sender)
+	assert.Equal(t, repository.SystemAccount, created.Recipient)
+	assert.True(t, decimal.NewFromInt(8000).Equal(created.Amount))
+}
+
+func TestCheckSystemPoolSkipsSourceBelowRequiredBalance(t *testing.T) {
+	r := newMemoryRepositories(t)
+
+	// USD is drained by withdrawals, EUR is filled by deposits
+	r.ledger.SetBalance(repository.SystemAccount, "USD", decimal.NewFromInt(5000))
+	r.ledger.SetBalance(repository.SystemAccount, "EUR", decimal.NewFromInt(50000))
+	r.ledger.ApplyLedgerEntries(
+		model.LedgerEntry{TransferId: uuid.New(), Account: repository.SystemAccount, Asset: "USD", Amount: decimal.NewFromInt(-3000), Type: model.TransferLedgerEntryType},
+		model.LedgerEntry{TransferId: uuid.New(), Account: repository.SystemAccount, Asset: "EUR", Amount: decimal.NewFromInt(10000), Type: model.TransferLedgerEntryType},
+	)
+
+	settings := repository.NewMemoryPoolRebalanceSettingRepository(r.store)
+	settings.SetPoolRebalanceSetting(model.PoolRebalanceSetting{Asset: "USD", ImbalanceThreshold: 0.5, MinimumBalance: decimal.NewFromInt(10000), TopUpAmount: decimal.NewFromInt(5000), RequiredBalanceForTopUp: decimal.NewFromInt(20000)})
+	settings.SetPoolRebalanceSetting(model.PoolRebalanceSetting{Asset: "EUR", ImbalanceThreshold: 0.5, MinimumBalance: decimal.NewFromInt(10000), TopUpAmount: decimal.NewFromInt(8000), RequiredBalanceForTopUp: decimal.NewFromInt(100000)})
+
+	bus := eventbus.NewMemoryBus()
+	eventService := NewEventService(bus)
+	service := NewPoolRebalancerService(zap.NewNop(), context.Background(), repository.NewMemoryRateRepository(r.store),
+		r.transfers, r.ledger, &eventService, config.Config{}, settings)
+
+	assert.NoError(t, service.checkSystemPool())
+	assert.Empty(t, bus.Messages(TransferTopic))
+}
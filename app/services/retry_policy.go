@@ -0,0 +1,41 @@
+package services
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy spaces out the attempts of a transfer that failed with a transient error with exponential backoff
+type RetryPolicy struct {
+	BaseDelay   time.Duration // delay before the second attempt, doubled for every further attempt
+	MaxDelay    time.Duration
+	MaxAttempts int // the transfer is failed once it failed this many attempts
+}
+
+// ShouldRetry reports whether a transfer that failed its attempt-th attempt gets another one
+func (r RetryPolicy) ShouldRetry(attempt int) bool {
+	return attempt < r.MaxAttempts
+}
+
+// Backoff returns the delay after the attempt-th attempt - half of the exponential delay is fixed and half is random, so
+// transfers that failed together, e.g. on a database blip, are not all retried at the same time
+func (r RetryPolicy) Backoff(attempt int, random *rand.Rand) time.Duration {
+	delay := r.MaxDelay
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	// past 2^30 the delay is capped anyway, and the shift would overflow
+	if attempt <= 30 {
+		if exponential := r.BaseDelay << (attempt - 1); exponential > 0 && exponential < r.MaxDelay {
+			delay = exponential
+		}
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + time.Duration(random.Int63n(int64(half)+1))
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, MaxAttempts: 5}
+	random := rand.New(rand.NewSource(1))
+
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 7: time.Minute, 100: time.Minute} {
+		for i := 0; i < 20; i++ {
+			backoff := policy.Backoff(attempt, random)
+			assert.GreaterOrEqual(t, backoff, expected/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, backoff, expected, "attempt %d", attempt)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	assert.True(t, policy.ShouldRetry(1))
+	assert.True(t, policy.ShouldRetry(2))
+	assert.False(t, policy.ShouldRetry(3))
+}
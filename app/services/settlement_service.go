@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"sphere-homework/app/config"
+	eventModel "sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"time"
+)
+
+// SettlementTimeoutReason is the failure reason of sent transfers the rail never confirmed
+const SettlementTimeoutReason = "settlement timed out"
+
+// SettlementService is responsible for:
+// 1. Listening to the event bus for transfer settled events from the payout rail
+// 2. Moving sent transfers to completed, or to failed with their ledger entries reversed
+// 3. Polling the payout rails for sent transfers, and failing those not confirmed within the settlement timeout
+// 4. Reversing sent or completed transfers on demand, e.g. when the rail returned a payout
+type SettlementService struct {
+	*lifecycle
+	subscriber         eventbus.Subscriber
+	logger             *zap.Logger
+	transferRepository repository.TransferRepository
+	payoutRails        *PayoutRails
+	deadLetterService  *DeadLetterService
+	config             config.Config
+}
+
+func NewSettlementService(subscriber eventbus.Subscriber, logger *zap.Logger, transferRepository repository.TransferRepository,
+	payoutRails *PayoutRails, deadLetterService *DeadLetterService, ctx context.Context, config config.Config) *SettlementService {
+
+	return &SettlementService{
+		lifecycle:          newLifecycle(ctx, "settlement service"),
+		subscriber:         subscriber,
+		logger:             logger,
+		transferRepository: transferRepository,
+		payoutRails:        payoutRails,
+		deadLetterService:  deadLetterService,
+		config:             config,
+	}
+}
+
+func (s *SettlementService) Init() error {
+	err := s.subscriber.Subscribe(TransferTopic)
+	if err != nil {
+		return err
+	}
+
+	// this go-routine listens to kafka for transfer settled events - and settles the transfer
+	s.run(func() {
+		s.logger.Info("Starting settlement service consumer")
+		consumeMessages(s.ctx, s.subscriber, s.logger, SettlementServiceConsumer, s.deadLetterService, s.handleMessage)
+	})
+
+	// this go-routine settles the sent transfers the rail confirmed since, and fails those it did not confirm in time
+	s.run(func() {
+		s.logger.Info("Starting settlement sweeper")
+
+		ticker := time.NewTicker(time.Duration(s.config.SettlementSweepFrequencySec) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				s.logger.Info("Shutting down settlement sweeper")
+				return
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	})
+
+	return nil
+}
+
+// Stop stops consuming transfer settled events and sweeping, and closes the subscriber once its go-routines finished
+func (s *SettlementService) Stop(ctx context.Context) error {
+	if err := s.lifecycle.Stop(ctx); err != nil {
+		return err
+	}
+
+	return s.subscriber.Close()
+}
+
+func (s *SettlementService) sweep() {
+	now := time.Now().UTC()
+	timeout := time.Duration(s.config.SettlementTimeoutSec) * time.Second
+
+	transfers, err := s.transferRepository.GetUnsettledTransfers(now, 250)
+	if err != nil {
+		s.logger.Error("Unable to get unsettled transfers", zap.Error(err))
+		return
+	}
+
+	for _, transfer := range transfers {
+		logger := s.logger.With(zap.String("id", transfer.TransferId.String()))
+
+		payout, err := s.payoutRails.Get(transfer.ToAsset).GetPayout(transfer.TransferId)
+		if err != nil {
+			logger.Error("Unable to get payout from rail", zap.Error(err))
+		}
+
+		var settlement model.Settlement
+
+		if payout != nil && payout.Status != model.PendingPayoutStatus {
+			settlement = payout.Settlement()
+		} else if transfer.SentAt.Add(timeout).Before(now) {
+			settlement = model.Settlement{
+				TransferId:    transfer.TransferId,
+				Status:        model.FailedTransferStatus,
+				FailureReason: SettlementTimeoutReason,
+			}
+		} else {
+			continue
+		}
+
+		if _, err = s.Settle(settlement); err != nil {
+			logger.Error("Unable to settle transfer", zap.Error(err))
+		}
+	}
+}
+
+// Settle applies the rail's confirmation to a sent transfer and publishes the outcome - it returns nil if the transfer
+// is not sent, e.g. because it was already settled
+func (s *SettlementService) Settle(settlement model.Settlement) (*model.Transfer, error) {
+	switch settlement.Status {
+	case model.CompletedTransferStatus:
+		return s.complete(settlement.TransferId)
+	case model.FailedTransferStatus:
+		// the ledger was debited when the transfer was sent, so a failed payout is refunded to the sender
+		reversal, err := s.reverse(settlement.TransferId, settlement.FailureReason, model.SentTransferStatus)
+		if err != nil || reversal == nil {
+			return nil, err
+		}
+
+		return &reversal.Transfer, nil
+	default:
+		return nil, fmt.Errorf("invalid settlement status: %s", settlement.Status)
+	}
+}
+
+// Reverse fails a sent or completed transfer and refunds the sender, e.g. after the rail returned a payout it had
+// confirmed - it returns nil if the transfer is neither sent nor completed
+func (s *SettlementService) Reverse(transferId uuid.UUID, reason string) (*model.Reversal, error) {
+	return s.reverse(transferId, reason, model.SentTransferStatus, model.CompletedTransferStatus)
+}
+
+func (s *SettlementService) complete(transferId uuid.UUID) (*model.Transfer, error) {
+	logger := s.logger.With(zap.String("id", transferId.String()))
+
+	completed, err := s.transferRepository.CompleteTransfer(transferId)
+	if err != nil {
+		return nil, err
+	}
+
+	if completed == nil {
+		logger.Info("Ignoring completion of transfer that is not sent")
+		return nil, nil
+	}
+
+	logger.Info("Completed transfer", zap.Any("transfer", completed))
+
+	return completed, nil
+}
+
+func (s *SettlementService) reverse(transferId uuid.UUID, reason string, statuses ...model.TransferStatus) (*model.Reversal, error) {
+	logger := s.logger.With(zap.String("id", transferId.String()))
+
+	reversal, err := s.transferRepository.ReverseTransfer(transferId, reason, statuses...)
+	if err != nil {
+		return nil, err
+	}
+
+	if reversal == nil {
+		logger.Info("Ignoring reversal of transfer", zap.Any("statuses", statuses))
+		return nil, nil
+	}
+
+	logger.Info("Reversed transfer", zap.Any("reversal", reversal))
+
+	return reversal, nil
+}
+
+func (s *SettlementService) handleMessage(msg eventbus.Message) error {
+	event := eventModel.BaseEvent{}
+
+	err := json.Unmarshal(msg.Value, &event)
+	if err != nil {
+		return err
+	}
+
+	// ignore non transfer_settled events
+	if event.EventType != eventModel.TransferSettledEventType {
+		return nil
+	}
+
+	settled := eventModel.TransferSettled{}
+
+	err = json.Unmarshal(event.Payload, &settled)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Received TransferSettled event", zap.Any("settlement", settled))
+
+	_, err = s.Settle(model.Settlement{
+		TransferId:    settled.TransferId,
+		Status:        settled.Status,
+		FailureReason: settled.FailureReason,
+	})
+
+	return err
+}
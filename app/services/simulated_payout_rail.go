@@ -0,0 +1,111 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"math/rand"
+	"sphere-homework/app/model"
+	"sync"
+	"time"
+)
+
+const (
+	SimulatedRailRejectionReason = "rejected by simulated rail"
+	SimulatedRailFailureReason   = "payout failed on simulated rail"
+)
+
+type SimulatedPayoutRailSetting struct {
+	SubmitLatency   time.Duration // how long a submission takes
+	SettlementDelay time.Duration // how long after the submission the payout is completed or failed
+	RejectionRate   float64       // ratio of submissions rejected right away
+	FailureRate     float64       // ratio of accepted payouts that fail once settled
+	TimeoutRate     float64       // ratio of accepted payouts that are never settled
+}
+
+type simulatedPayout struct {
+	payout    model.Payout
+	outcome   model.PayoutStatus // status once settled, pending if the payout never settles
+	settledAt time.Time
+}
+
+// SimulatedPayoutRail is an in-memory payout rail for local runs and tests - payouts are forgotten on restart, which
+// leaves them to the settlement timeout
+type SimulatedPayoutRail struct {
+	setting SimulatedPayoutRailSetting
+	random  *rand.Rand
+	now     func() time.Time
+	mu      sync.Mutex
+	payouts map[uuid.UUID]*simulatedPayout
+}
+
+func NewSimulatedPayoutRail(setting SimulatedPayoutRailSetting) *SimulatedPayoutRail {
+	return &SimulatedPayoutRail{
+		setting: setting,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:     time.Now,
+		payouts: map[uuid.UUID]*simulatedPayout{},
+	}
+}
+
+func (s *SimulatedPayoutRail) Submit(transfer model.Transfer) (*model.Payout, error) {
+	time.Sleep(s.setting.SubmitLatency)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a resubmission of the same transfer is not paid out twice
+	if existing, ok := s.payouts[transfer.TransferId]; ok {
+		payout := s.current(existing)
+		return &payout, nil
+	}
+
+	simulated := &simulatedPayout{
+		payout: model.Payout{
+			TransferId: transfer.TransferId,
+			Reference:  uuid.NewString(),
+			Status:     model.PendingPayoutStatus,
+		},
+		outcome:   model.CompletedPayoutStatus,
+		settledAt: s.now().Add(s.setting.SettlementDelay),
+	}
+
+	switch roll := s.random.Float64(); {
+	case roll < s.setting.RejectionRate:
+		simulated.payout.Status = model.FailedPayoutStatus
+		simulated.payout.FailureReason = SimulatedRailRejectionReason
+		simulated.outcome = model.FailedPayoutStatus
+	case roll < s.setting.RejectionRate+s.setting.FailureRate:
+		simulated.outcome = model.FailedPayoutStatus
+	case roll < s.setting.RejectionRate+s.setting.FailureRate+s.setting.TimeoutRate:
+		simulated.outcome = model.PendingPayoutStatus
+	}
+
+	s.payouts[transfer.TransferId] = simulated
+
+	payout := simulated.payout
+	return &payout, nil
+}
+
+func (s *SimulatedPayoutRail) GetPayout(transferId uuid.UUID) (*model.Payout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	simulated, ok := s.payouts[transferId]
+	if !ok {
+		return nil, nil
+	}
+
+	payout := s.current(simulated)
+	return &payout, nil
+}
+
+// current settles the payout once its settlement delay passed
+func (s *SimulatedPayoutRail) current(simulated *simulatedPayout) model.Payout {
+	if simulated.payout.Status == model.PendingPayoutStatus && !s.now().Before(simulated.settledAt) {
+		simulated.payout.Status = simulated.outcome
+		if simulated.outcome == model.FailedPayoutStatus {
+			simulated.payout.FailureReason = SimulatedRailFailureReason
+		}
+	}
+
+	return simulated.payout
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/model"
+	"testing"
+	"time"
+)
+
+func newTestSimulatedPayoutRail(setting SimulatedPayoutRailSetting, now *time.Time) *SimulatedPayoutRail {
+	rail := NewSimulatedPayoutRail(setting)
+	rail.now = func() time.Time { return *now }
+	return rail
+}
+
+func TestSimulatedPayoutRailCompletesAfterSettlementDelay(t *testing.T) {
+	now := time.Now()
+	rail := newTestSimulatedPayoutRail(SimulatedPayoutRailSetting{SettlementDelay: time.Minute}, &now)
+	transfer := model.Transfer{TransferId: uuid.New()}
+
+	payout, err := rail.Submit(transfer)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PendingPayoutStatus, payout.Status)
+	assert.NotEmpty(t, payout.Reference)
+
+	payout, err = rail.GetPayout(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PendingPayoutStatus, payout.Status)
+
+	now = now.Add(time.Minute)
+
+	payout, err = rail.GetPayout(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.CompletedPayoutStatus, payout.Status)
+
+	// resubmitting returns the existing payout
+	resubmitted, err := rail.Submit(transfer)
+	assert.NoError(t, err)
+	assert.Equal(t, *payout, *resubmitted)
+}
+
+func TestSimulatedPayoutRailRejectsSubmission(t *testing.T) {
+	now := time.Now()
+	rail := newTestSimulatedPayoutRail(SimulatedPayoutRailSetting{RejectionRate: 1}, &now)
+
+	payout, err := rail.Submit(model.Transfer{TransferId: uuid.New()})
+	assert.NoError(t, err)
+	assert.Equal(t, model.FailedPayoutStatus, payout.Status)
+	assert.Equal(t, SimulatedRailRejectionReason, payout.FailureReason)
+}
+
+func TestSimulatedPayoutRailFailsAfterSettlementDelay(t *testing.T) {
+	now := time.Now()
+	rail := newTestSimulatedPayoutRail(SimulatedPayoutRailSetting{SettlementDelay: time.Second, FailureRate: 1}, &now)
+	transfer := model.Transfer{TransferId: uuid.New()}
+
+	payout, err := rail.Submit(transfer)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PendingPayoutStatus, payout.Status)
+
+	now = now.Add(time.Second)
+
+	payout, err = rail.GetPayout(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FailedPayoutStatus, payout.Status)
+	assert.Equal(t, SimulatedRailFailureReason, payout.FailureReason)
+	assert.Equal(t, model.FailedTransferStatus, payout.Settlement().Status)
+}
+
+func TestSimulatedPayoutRailTimesOut(t *testing.T) {
+	now := time.Now()
+	rail := newTestSimulatedPayoutRail(SimulatedPayoutRailSetting{TimeoutRate: 1}, &now)
+	transfer := model.Transfer{TransferId: uuid.New()}
+
+	_, err := rail.Submit(transfer)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Hour)
+
+	payout, err := rail.GetPayout(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PendingPayoutStatus, payout.Status)
+}
+
+func TestSimulatedPayoutRailUnknownTransfer(t *testing.T) {
+	rail := NewSimulatedPayoutRail(SimulatedPayoutRailSetting{})
+
+	payout, err := rail.GetPayout(uuid.New())
+	assert.NoError(t, err)
+	assert.Nil(t, payout)
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/csv"
+	"io"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/model"
+	"time"
+)
+
+const (
+	openingBalanceRowType = "OPENING_BALANCE"
+	closingBalanceRowType = "CLOSING_BALANCE"
+)
+
+var statementCSVHeader = []string{"account", "asset", "created_at", "transfer_id", "entry_type", "amount", "running_balance"}
+
+func ToStatementResponse(statement model.Statement) dto.StatementResponse {
+	response := dto.StatementResponse{
+		Account: statement.Account,
+		From:    statement.From,
+		To:      statement.To,
+		Assets:  []dto.AssetStatementResponse{},
+	}
+
+	for _, asset := range statement.Assets {
+		assetResponse := dto.AssetStatementResponse{
+			Asset:          asset.Asset,
+			OpeningBalance: asset.OpeningBalance,
+			ClosingBalance: asset.ClosingBalance,
+			Entries:        []dto.StatementEntryResponse{},
+		}
+
+		for _, entry := range asset.Entries {
+			assetResponse.Entries = append(assetResponse.Entries, dto.StatementEntryResponse{
+				CreatedAt:      entry.CreatedAt,
+				TransferId:     entry.TransferId,
+				EntryType:      string(entry.Type),
+				Asset:          entry.Asset,
+				Amount:         entry.Amount,
+				RunningBalance: entry.RunningBalance,
+			})
+		}
+
+		response.Assets = append(response.Assets, assetResponse)
+	}
+
+	return response
+}
+
+// WriteStatementCSV writes one row per ledger entry, framed by an opening and a closing balance row per asset
+func WriteStatementCSV(w io.Writer, statement model.Statement) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(statementCSVHeader); err != nil {
+		return err
+	}
+
+	for _, asset := range statement.Assets {
+		err := writer.Write([]string{statement.Account, asset.Asset, statement.From.UTC().Format(time.RFC3339Nano), "", openingBalanceRowType, "", asset.OpeningBalance.String()})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range asset.Entries {
+			err = writer.Write([]string{statement.Account, asset.Asset, entry.CreatedAt.UTC().Format(time.RFC3339Nano), entry.TransferId.String(), string(entry.Type), entry.Amount.String(), entry.RunningBalance.String()})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writer.Write([]string{statement.Account, asset.Asset, statement.To.UTC().Format(time.RFC3339Nano), "", closingBalanceRowType, "", asset.ClosingBalance.String()})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// ParseStatementTime accepts either a date (YYYY-MM-DD, midnight UTC) or an RFC3339 timestamp
+func ParseStatementTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
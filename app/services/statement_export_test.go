@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteStatementCSV(t *testing.T) {
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	transferId := uuid.MustParse("0b9c6c43-4bd2-4f7b-9d0f-8a1d2f5e4c11")
+
+	statement := model.Statement{
+		Account: "jim",
+		From:    from,
+		To:      to,
+		Assets: []model.AssetStatement{
+			model.NewAssetStatement("USD", decimal.NewFromInt(3500), []model.StatementEntry{
+				{
+					CreatedAt:  time.Date(2024, 10, 5, 12, 0, 0, 0, time.UTC),
+					TransferId: transferId,
+					Type:       model.TransferLedgerEntryType,
+					Asset:      "USD",
+					Amount:     decimal.RequireFromString("-30.00"),
+				},
+			}),
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WriteStatementCSV(&buf, statement)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, []string{
+		"account,asset,created_at,transfer_id,entry_type,amount,running_balance",
+		"jim,USD,2024-10-01T00:00:00Z,,OPENING_BALANCE,,3500",
+		"jim,USD,2024-10-05T12:00:00Z,0b9c6c43-4bd2-4f7b-9d0f-8a1d2f5e4c11,TRANSFER,-30,3470",
+		"jim,USD,2024-11-01T00:00:00Z,,CLOSING_BALANCE,,3470",
+	}, lines)
+}
+
+func TestParseStatementTime(t *testing.T) {
+	date, err := ParseStatementTime("2024-10-01")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), date)
+
+	timestamp, err := ParseStatementTime("2024-10-01T10:00:00+02:00")
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2024, 10, 1, 8, 0, 0, 0, time.UTC).Equal(timestamp))
+
+	_, err = ParseStatementTime("October")
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"net"
+)
+
+// isTransientTransferError reports whether an attempt to process a transfer may succeed if retried, i.e. the database
+// or the network was unavailable - any other error, like an insufficient balance or an unknown one, is permanent
+func isTransientTransferError(err error) bool {
+	return isUnavailableError(err)
+}
+
+// isUnavailableError reports whether the error was caused by the database or the network being unavailable rather than
+// by the request itself, unknown errors are not
+func isUnavailableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) || pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:2] {
+		// connection exception, transaction rollback (serialization failure, deadlock), insufficient resources,
+		// operator intervention (e.g. admin shutdown) and system error
+		case "08", "40", "53", "57", "58":
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
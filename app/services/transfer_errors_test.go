@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/repository"
+	"testing"
+)
+
+func TestIsTransientTransferError(t *testing.T) {
+	assert.False(t, isTransientTransferError(fmt.Errorf("unable to perform transfer to ledger: %w", repository.ErrInsufficientBalance)))
+	assert.False(t, isTransientTransferError(pgx.ErrNoRows))
+	assert.False(t, isTransientTransferError(fmt.Errorf("failed to apply ledger entry: %w", &pgconn.PgError{Code: "23514"})))
+	assert.False(t, isTransientTransferError(repository.ErrLockReclaimed))
+	assert.False(t, isTransientTransferError(fmt.Errorf("something unexpected")))
+
+	assert.True(t, isTransientTransferError(fmt.Errorf("failed to apply ledger entry: %w", &pgconn.PgError{Code: "40P01"})))
+	assert.True(t, isTransientTransferError(&pgconn.PgError{Code: "08006"}))
+	assert.True(t, isTransientTransferError(context.DeadlineExceeded))
+}
+
+func TestIsUnavailableError(t *testing.T) {
+	assert.True(t, isUnavailableError(fmt.Errorf("failed to insert: %w", &pgconn.PgError{Code: "57P01"})))
+	assert.True(t, isUnavailableError(context.DeadlineExceeded))
+
+	assert.False(t, isUnavailableError(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, isUnavailableError(fmt.Errorf("invalid character 'x' looking for beginning of value")))
+}
@@ -3,60 +3,57 @@ package services
 import (
 	"context"
 	"encoding/json"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"go.uber.org/zap"
 	eventModel "sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
 	"sphere-homework/app/repository"
 )
 
 type TransferHistoryService struct {
-	consumer   *kafka.Consumer
-	logger     *zap.Logger
-	ctx        context.Context
-	repository *repository.TransferHistoryRepository
+	*lifecycle
+	subscriber        eventbus.Subscriber
+	logger            *zap.Logger
+	repository        repository.TransferHistoryRepository
+	deadLetterService *DeadLetterService
 }
 
-func NewTransferHistoryService(ctx context.Context, consumer *kafka.Consumer, logger *zap.Logger, repository *repository.TransferHistoryRepository) *TransferHistoryService {
+func NewTransferHistoryService(ctx context.Context, subscriber eventbus.Subscriber, logger *zap.Logger, repository repository.TransferHistoryRepository,
+	deadLetterService *DeadLetterService) *TransferHistoryService {
+
 	return &TransferHistoryService{
-		consumer:   consumer,
-		logger:     logger,
-		ctx:        ctx,
-		repository: repository,
+		lifecycle:         newLifecycle(ctx, "transfer history service"),
+		subscriber:        subscriber,
+		logger:            logger,
+		repository:        repository,
+		deadLetterService: deadLetterService,
 	}
 }
 
 func (t *TransferHistoryService) Init() error {
-	err := t.consumer.Subscribe(TransferTopic, nil)
+	err := t.subscriber.Subscribe(TransferTopic)
 	if err != nil {
 		return err
 	}
 
 	// this go-routine listens to kafka for all transfer events - and writes it to the transfer_history table
-	go func() {
+	t.run(func() {
 		t.logger.Info("Starting transfer history service consumer")
-		for {
-			msg, err := t.consumer.ReadMessage(-1)
-			if err != nil {
-				t.logger.Error("Error reading message from consumer", zap.Error(err))
-				continue
-			}
+		consumeMessages(t.ctx, t.subscriber, t.logger, TransferHistoryServiceConsumer, t.deadLetterService, t.handleMessage)
+	})
 
-			if msg == nil {
-				continue
-			}
+	return nil
+}
 
-			err = t.handleMessage(msg)
-			if err != nil {
-				t.logger.Error("Unable to handle the event", zap.Error(err))
-				continue
-			}
-		}
-	}()
+// Stop stops consuming transfer events, and closes the subscriber once its go-routines finished
+func (t *TransferHistoryService) Stop(ctx context.Context) error {
+	if err := t.lifecycle.Stop(ctx); err != nil {
+		return err
+	}
 
-	return nil
+	return t.subscriber.Close()
 }
 
-func (t *TransferHistoryService) handleMessage(msg *kafka.Message) error {
+func (t *TransferHistoryService) handleMessage(msg eventbus.Message) error {
 	event := eventModel.BaseEvent{}
 
 	err := json.Unmarshal(msg.Value, &event)
@@ -3,85 +3,87 @@ package services
 import (
 	"context"
 	"encoding/json"
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"math/rand"
 	"sphere-homework/app/config"
 	eventModel "sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/metrics"
 	"sphere-homework/app/model"
 	"sphere-homework/app/repository"
+	"sync"
 	"time"
 )
 
-// TransferDelay provides a map of artificial transfer delay (sec) by destination asset
-var TransferDelay = map[string]time.Duration{
-	"USD": time.Duration(3) * time.Second,
-	"EUR": time.Duration(2) * time.Second,
-	"JPY": time.Duration(3) * time.Second,
-	"GBP": time.Duration(2) * time.Second,
-	"AUD": time.Duration(3) * time.Second,
-}
+// DuplicateIdempotencyKeyReason is the failure reason of transfers whose idempotency key the outbox already recorded for
+// another transfer of the sender
+const DuplicateIdempotencyKeyReason = "idempotency key already used by another transfer"
 
 // TransferService is responsible for:
 // 1. Listening to the event bus for transfer created events
 // 2. Creating a transfer order on the outbox table
-// 3. Fulfilling the orders placed on the outbox table
+// 3. Fulfilling the orders placed on the outbox table, and submitting them to the payout rail of the destination asset
 type TransferService struct {
-	consumer           *kafka.Consumer
+	*lifecycle
+	subscriber         eventbus.Subscriber
 	logger             *zap.Logger
-	ctx                context.Context
-	transferRepository *repository.TransferRepository
-	ledgerRepository   *repository.LedgerRepository
+	transferRepository repository.TransferRepository
+	ledgerRepository   repository.LedgerRepository
+	assetRegistry      *repository.AssetRegistry
 	config             config.Config
-	eventService       *EventService
+	payoutRails        *PayoutRails
+	settlementService  *SettlementService
+	deadLetterService  *DeadLetterService
+	retryPolicy        RetryPolicy
+
+	// outbox workers by destination asset, which are drained before the outbox processor shuts down
+	workersMu     sync.Mutex
+	activeWorkers map[string]int
+	workers       sync.WaitGroup
 }
 
-func NewTransferService(consumer *kafka.Consumer, logger *zap.Logger, transferRepository *repository.TransferRepository,
-	ledgerRepository *repository.LedgerRepository, eventService *EventService, ctx context.Context, config config.Config) *TransferService {
+func NewTransferService(subscriber eventbus.Subscriber, logger *zap.Logger, transferRepository repository.TransferRepository,
+	ledgerRepository repository.LedgerRepository, assetRegistry *repository.AssetRegistry,
+	payoutRails *PayoutRails, settlementService *SettlementService, deadLetterService *DeadLetterService, ctx context.Context, config config.Config) *TransferService {
 
 	return &TransferService{
-		consumer:           consumer,
+		lifecycle:          newLifecycle(ctx, "transfer service"),
+		subscriber:         subscriber,
 		logger:             logger,
 		transferRepository: transferRepository,
 		ledgerRepository:   ledgerRepository,
-		ctx:                ctx,
+		assetRegistry:      assetRegistry,
 		config:             config,
-		eventService:       eventService,
+		payoutRails:        payoutRails,
+		settlementService:  settlementService,
+		deadLetterService:  deadLetterService,
+		retryPolicy: RetryPolicy{
+			BaseDelay:   time.Duration(config.TransferRetryBaseDelayMs) * time.Millisecond,
+			MaxDelay:    time.Duration(config.TransferRetryMaxDelayMs) * time.Millisecond,
+			MaxAttempts: config.TransferMaxAttempts,
+		},
+		activeWorkers: map[string]int{},
 	}
 }
 
 func (t *TransferService) Init() error {
-	err := t.consumer.Subscribe(TransferTopic, nil)
+	err := t.subscriber.Subscribe(TransferTopic)
 	if err != nil {
 		return err
 	}
 
 	// this go-routine listens to kafka for transfer created events - and writes it to the outbox
-	go func() {
+	t.run(func() {
 		t.logger.Info("Starting transfer service consumer")
-		for {
-			msg, err := t.consumer.ReadMessage(-1)
-			if err != nil {
-				t.logger.Error("Error reading message from consumer", zap.Error(err))
-				continue
-			}
-
-			if msg == nil {
-				continue
-			}
-
-			err = t.handleMessage(msg)
-			if err != nil {
-				t.logger.Error("Unable to handle the event", zap.Error(err))
-				continue
-			}
-
-		}
-	}()
+		consumeMessages(t.ctx, t.subscriber, t.logger, TransferServiceConsumer, t.deadLetterService, t.handleMessage)
+	})
 
-	// this go-routine polls the outbox for unsent transfers, and sends them -
+	// this go-routine polls the outbox for the destination assets with unsent transfers, and starts workers that send them -
 	// to do - maybe schedule this as a cron in a more reliable task scheduler like asynq
-	go func() {
+	t.run(func() {
 		t.logger.Info("Starting transfer outbox processor")
 
 		ticker := time.NewTicker(time.Duration(t.config.TransferOutboxPollFrequencySec) * time.Second)
@@ -90,118 +92,263 @@ func (t *TransferService) Init() error {
 		for {
 			select {
 			case <-t.ctx.Done():
+				t.logger.Info("Draining transfer outbox workers")
+				t.workers.Wait()
 				t.logger.Info("Shutting down transfer outbox processor")
 				return
 			case <-ticker.C:
-				transfers, err := t.transferRepository.GetUnsentTransfers(250)
-
+				assets, err := t.transferRepository.GetUnsentTransferAssets()
 				if err != nil {
-					t.logger.Error("Unable to get unsent transfers", zap.Error(err))
+					t.logger.Error("Unable to get unsent transfer assets", zap.Error(err))
 					continue
 				}
 
-				// TODO: this transfer queue processor can probably be parallelized by some sort of partition - like destination asset
-				for _, transfer := range transfers {
-					err = t.processTransfer(transfer)
-					if err != nil {
-						t.logger.Error("Unable to process transfer", zap.Any("transfer", transfer), zap.Error(err))
-						continue
-					}
+				for _, asset := range assets {
+					t.startOutboxWorkers(asset)
 				}
 			}
 		}
-	}()
+	})
+
+	// this go-routine reclaims the locks of outbox processors that died or stalled while processing a transfer, and
+	// releases the holds of transfers that never reached the outbox
+	t.run(func() {
+		t.logger.Info("Starting transfer lock reaper")
+
+		ticker := time.NewTicker(time.Duration(t.config.TransferLockReaperFrequencySec) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.ctx.Done():
+				t.logger.Info("Shutting down transfer lock reaper")
+				return
+			case <-ticker.C:
+				t.reapExpiredLocks()
+				t.releaseExpiredHolds()
+			}
+		}
+	})
 
 	return nil
 }
 
-func applyArtificialDelay(asset string) {
-	delay, ok := TransferDelay[asset]
-	if !ok {
-		delay = time.Duration(0) * time.Second
+// Stop stops consuming transfer created events and drains the outbox workers of their in-flight transfers, and closes
+// the subscriber once its go-routines finished
+func (t *TransferService) Stop(ctx context.Context) error {
+	if err := t.lifecycle.Stop(ctx); err != nil {
+		return err
 	}
 
-	time.Sleep(delay)
+	return t.subscriber.Close()
 }
 
-func (t *TransferService) processTransfer(transfer model.Transfer) error {
-	logger := t.logger.With(
-		zap.String("id", transfer.TransferId.String()),
-		zap.String("from_asset", transfer.FromAsset),
-		zap.String("to_asset", transfer.ToAsset))
+// random returns a source for the retry jitter - rand.Rand is not safe for concurrent use by the outbox workers
+func (t *TransferService) random() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
 
-	logger.Info("Processing transfer", zap.Any("transfer", transfer))
+// startOutboxWorkers tops up the workers of the destination asset to its configured concurrency - workers exit once
+// there are no unsent transfers left to claim, or the service shuts down
+func (t *TransferService) startOutboxWorkers(asset string) {
+	t.workersMu.Lock()
+	defer t.workersMu.Unlock()
 
-	applyArtificialDelay(transfer.ToAsset)
+	for t.activeWorkers[asset] < t.config.TransferWorkers(asset) {
+		t.activeWorkers[asset]++
+		t.workers.Add(1)
 
-	// Lock transfer so it cannot be picked up by another transfer processor in case we have multiple instances of it running
-	lockedTransfer, err := t.transferRepository.LockTransfer(transfer.TransferId)
-	if err != nil {
-		logger.Error("Unable to lock transfer", zap.Error(err))
-		return err
+		go t.runOutboxWorker(asset)
 	}
+}
 
+func (t *TransferService) runOutboxWorker(asset string) {
 	defer func() {
-		var event *eventModel.BaseEvent
-		var errPub error
+		t.workersMu.Lock()
+		t.activeWorkers[asset]--
+		t.workersMu.Unlock()
 
-		if err != nil {
-			lockedTransfer.TransferStatus = model.FailedTransferStatus
-			reason := err.Error()
-			lockedTransfer.FailureReason = &reason
-
-			logger.Info("Failed to process transfer", zap.Any("transfer", lockedTransfer))
+		t.workers.Done()
+	}()
 
-			event, errPub = eventModel.NewTransferFailed(*lockedTransfer)
-			if errPub != nil {
-				logger.Error("Unable to create transfer failed event", zap.Error(err))
-			}
-		} else {
-			now := time.Now().UTC()
-			lockedTransfer.TransferStatus = model.SentTransferStatus
-			lockedTransfer.SentAt = &now
+	lease := time.Duration(t.config.TransferLockLeaseSec) * time.Second
 
-			logger.Info("Successfully processed transfer", zap.Any("transfer", lockedTransfer))
+	// a worker finishes the transfer it claimed before checking for shutdown
+	for t.ctx.Err() == nil {
+		// claim one transfer at a time, so a claimed transfer never waits for the lease of another one to be processed
+		transfers, err := t.transferRepository.ClaimUnsentTransfers(asset, 1, lease)
+		if err != nil {
+			t.logger.Error("Unable to claim unsent transfers", zap.String("to_asset", asset), zap.Error(err))
+			return
+		}
 
-			event, errPub = eventModel.NewTransferSent(*lockedTransfer)
-			if errPub != nil {
-				logger.Error("Unable to create transfer sent event", zap.Error(err))
-			}
+		if len(transfers) == 0 {
+			return
 		}
 
-		err = t.eventService.PublishEvent(*event)
+		err = t.processTransfer(transfers[0])
 		if err != nil {
-			logger.Error("Unable to publish transfer sent event", zap.Error(err))
+			t.logger.Error("Unable to process transfer", zap.Any("transfer", transfers[0]), zap.Error(err))
 		}
+	}
+}
+
+func (t *TransferService) reapExpiredLocks() {
+	transfers, err := t.transferRepository.GetExpiredLocks(250)
+	if err != nil {
+		t.logger.Error("Unable to get expired transfer locks", zap.Error(err))
+		return
+	}
 
-		_, err = t.transferRepository.UnlockAndUpdateTransfer(*lockedTransfer)
+	for _, transfer := range transfers {
+		logger := t.logger.With(zap.String("id", transfer.TransferId.String()), zap.Any("lock_id", transfer.LockId))
+
+		reclaim, recovered, err := t.transferRepository.ReclaimLock(transfer)
 		if err != nil {
-			logger.Error("Unable to unlock transfer", zap.Error(err))
-			return
+			logger.Error("Unable to reclaim transfer lock", zap.Error(err))
+			continue
 		}
 
-	}()
+		if reclaim == model.NoLockReclaim {
+			continue
+		}
 
-	err = t.ledgerRepository.InsertNewEntryIfNotExists(lockedTransfer.FromAsset, lockedTransfer.Sender)
-	if err != nil {
-		return err
+		metrics.ReclaimedTransferLocks.Add(string(reclaim), 1)
+		logger.Info("Reclaimed expired transfer lock", zap.String("reclaim", string(reclaim)))
+
+		// the processor died before handing the transfer over to the rail
+		if recovered != nil && recovered.TransferType == model.ExternalTransferType {
+			t.submitPayout(*recovered)
+		}
 	}
+}
+
+// releaseExpiredHolds frees the funds held for transfers whose created event never reached the transfer outbox, e.g.
+// because it was lost after the api could not confirm its delivery
+func (t *TransferService) releaseExpiredHolds() {
+	expiry := time.Duration(t.config.TransferHoldExpirySec) * time.Second
 
-	err = t.ledgerRepository.InsertNewEntryIfNotExists(lockedTransfer.ToAsset, lockedTransfer.Recipient)
+	holds, err := t.ledgerRepository.ReleaseExpiredHolds(expiry, 250)
 	if err != nil {
+		t.logger.Error("Unable to release expired holds", zap.Error(err))
+		return
+	}
+
+	for _, hold := range holds {
+		metrics.ReleasedExpiredHolds.Add(1)
+		t.logger.Info("Released expired hold", zap.String("id", hold.TransferId.String()), zap.String("account", hold.Account),
+			zap.String("asset", hold.Asset), zap.String("amount", hold.Amount.String()))
+	}
+}
+
+// processTransfer sends a transfer claimed by an outbox worker, the claim keeps other processors from picking it up
+func (t *TransferService) processTransfer(lockedTransfer model.Transfer) error {
+	logger := t.logger.With(
+		zap.String("id", lockedTransfer.TransferId.String()),
+		zap.String("from_asset", lockedTransfer.FromAsset),
+		zap.String("to_asset", lockedTransfer.ToAsset))
+
+	logger.Info("Processing transfer", zap.Any("transfer", lockedTransfer))
+
+	sentTransfer, err := t.sendTransfer(lockedTransfer)
+	if errors.Is(err, repository.ErrLockReclaimed) {
+		// another processor owns the transfer now and records its outcome
+		logger.Info("Dropping transfer, its lock was reclaimed", zap.Int("attempt", lockedTransfer.AttemptCount))
 		return err
 	}
 
-	err = t.ledgerRepository.Transfer(lockedTransfer)
 	if err != nil {
-		logger.Error("Unable to perform transfer to ledger", zap.Error(err))
+		if isTransientTransferError(err) && t.retryPolicy.ShouldRetry(lockedTransfer.AttemptCount) {
+			nextAttemptAt := time.Now().UTC().Add(t.retryPolicy.Backoff(lockedTransfer.AttemptCount, t.random()))
+			logger.Info("Retrying transfer", zap.Int("attempt", lockedTransfer.AttemptCount), zap.Time("next_attempt_at", nextAttemptAt), zap.Error(err))
+
+			if _, errRetry := t.transferRepository.RetryTransfer(lockedTransfer, nextAttemptAt, err.Error()); errRetry != nil {
+				logger.Error("Unable to schedule transfer retry", zap.Error(errRetry))
+			}
+
+			metrics.TransferRetries.Add(1)
+			return err
+		}
+
+		logger.Info("Failed to process transfer", zap.Any("transfer", lockedTransfer), zap.Int("attempt", lockedTransfer.AttemptCount), zap.Error(err))
+
+		if _, errFail := t.transferRepository.FailTransfer(lockedTransfer, err.Error()); errFail != nil {
+			logger.Error("Unable to fail transfer", zap.Error(errFail))
+		}
+
 		return err
 	}
 
+	logger.Info("Successfully processed transfer", zap.Any("transfer", sentTransfer))
+
+	// internal transfers stay within the system accounts, there is nothing to pay out
+	if sentTransfer.TransferType == model.InternalTransferType {
+		return nil
+	}
+
+	t.submitPayout(*sentTransfer)
+
 	return nil
 }
 
-func (t *TransferService) handleMessage(msg *kafka.Message) error {
+// submitPayout hands the sent transfer over to the payout rail of its destination asset - the ledger is debited at this
+// point, so a failed submission must not fail the transfer. If the outcome is unknown the settlement sweeper queries the
+// rail, and fails and reverses the transfer once it times out.
+func (t *TransferService) submitPayout(transfer model.Transfer) {
+	logger := t.logger.With(zap.String("id", transfer.TransferId.String()))
+
+	payout, err := t.payoutRails.Get(transfer.ToAsset).Submit(transfer)
+	if err != nil {
+		logger.Error("Unable to submit transfer to payout rail", zap.Error(err))
+		return
+	}
+
+	logger.Info("Submitted transfer to payout rail", zap.Any("payout", payout))
+
+	// the rail settled the payout right away, e.g. by rejecting it - otherwise it is settled later
+	if payout.Status != model.PendingPayoutStatus {
+		if _, err = t.settlementService.Settle(payout.Settlement()); err != nil {
+			logger.Error("Unable to settle transfer", zap.Error(err))
+		}
+	}
+}
+
+// sendTransfer applies the locked transfer to the ledger, and stores it as sent together with its transfer sent event
+func (t *TransferService) sendTransfer(transfer model.Transfer) (*model.Transfer, error) {
+	err := t.ledgerRepository.InsertNewEntryIfNotExists(transfer.FromAsset, transfer.Sender)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.ledgerRepository.InsertNewEntryIfNotExists(transfer.ToAsset, transfer.Recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.ledgerRepository.InsertNewEntryIfNotExists(transfer.ToAsset, repository.RoundingAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	transfer.TransferStatus = model.SentTransferStatus
+	transfer.SentAt = &now
+
+	// internal transfers only move funds between system accounts, there is no rail to confirm them
+	if transfer.TransferType == model.InternalTransferType {
+		transfer.TransferStatus = model.CompletedTransferStatus
+		transfer.SettledAt = &now
+	}
+
+	err = t.ledgerRepository.Transfer(&transfer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to perform transfer to ledger: %w", err)
+	}
+
+	return &transfer, nil
+}
+
+func (t *TransferService) handleMessage(msg eventbus.Message) error {
 	event := eventModel.BaseEvent{}
 
 	err := json.Unmarshal(msg.Value, &event)
@@ -210,7 +357,7 @@ func (t *TransferService) handleMessage(msg *kafka.Message) error {
 	}
 
 	// ignore non transfer_created events
-	if event.EventType != "transfer_created" {
+	if event.EventType != eventModel.TransferCreatedEventType {
 		return nil
 	}
 
@@ -224,10 +371,15 @@ func (t *TransferService) handleMessage(msg *kafka.Message) error {
 	t.logger.Info("Received TransferCreated event",
 		zap.String("key", string(msg.Key)),
 		zap.String("message", string(msg.Value)),
-		zap.Int32("partition", msg.TopicPartition.Partition),
+		zap.String("position", msg.Position()),
 		zap.Any("transfer", transferCreatedEvent),
 	)
 
+	// the transfer id handed out to the client is the id of the outbox entry, ledger entries and events
+	if transferCreatedEvent.TransferId == uuid.Nil {
+		return fmt.Errorf("transfer created event has no transfer id")
+	}
+
 	var transferType model.TransferType
 
 	// transfers like a re-balance is an internal transfer
@@ -237,10 +389,19 @@ func (t *TransferService) handleMessage(msg *kafka.Message) error {
 		transferType = model.ExternalTransferType
 	}
 
-	fee := transferCreatedEvent.Fee * transferCreatedEvent.Amount
+	// fee on the event is a ratio of the requested amount - round the actual fee charged to the source asset's minor unit
+	fee, err := t.assetRegistry.Round(transferCreatedEvent.FromAsset, transferCreatedEvent.Fee.Mul(transferCreatedEvent.Amount))
+	if err != nil {
+		return fmt.Errorf("unable to round transfer fee: %w", err)
+	}
+
+	var idempotencyKey *string
+	if transferCreatedEvent.IdempotencyKey != "" {
+		idempotencyKey = &transferCreatedEvent.IdempotencyKey
+	}
 
-	err = t.transferRepository.InsertOutgoingTransfer(model.Transfer{
-		TransferId:      uuid.New(),
+	transfer := model.Transfer{
+		TransferId:      transferCreatedEvent.TransferId,
 		CreatedAt:       time.Now().UTC(),
 		FromAsset:       transferCreatedEvent.FromAsset,
 		ToAsset:         transferCreatedEvent.ToAsset,
@@ -250,12 +411,39 @@ func (t *TransferService) handleMessage(msg *kafka.Message) error {
 		Sender:          transferCreatedEvent.Sender,
 		Recipient:       transferCreatedEvent.Recipient,
 		TransferType:    transferType,
-	})
+		IdempotencyKey:  idempotencyKey,
+	}
 
+	inserted, err := t.transferRepository.InsertOutgoingTransfer(transfer)
 	if err != nil {
 		t.logger.Error("Unable to insert outgoing transfer", zap.Error(err))
 		return err
 	}
 
+	if inserted {
+		return nil
+	}
+
+	existing, err := t.transferRepository.GetTransfer(transfer.TransferId)
+	if err != nil {
+		return err
+	}
+
+	// kafka redelivered the event
+	if existing != nil {
+		t.logger.Info("Ignoring duplicate TransferCreated event", zap.Any("transfer", transferCreatedEvent))
+		return nil
+	}
+
+	// the idempotency key was reserved for this transfer after it expired, but the transfer it was reserved for before
+	// reached the outbox later - the transfer is failed so the funds held for it are released
+	rejected, err := t.transferRepository.RejectTransfer(transfer, DuplicateIdempotencyKeyReason)
+	if err != nil {
+		t.logger.Error("Unable to reject outgoing transfer", zap.Error(err))
+		return err
+	}
+
+	t.logger.Info("Rejected TransferCreated event with an idempotency key in use", zap.Any("transfer", rejected))
+
 	return nil
 }
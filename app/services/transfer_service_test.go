@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sphere-homework/app/config"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/event"
+	"sphere-homework/app/eventbus"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"testing"
+	"time"
+)
+
+// memoryRepositories are the repositories of a MemoryStore the service tests run against, with the USD and EUR
+// assets registered
+type memoryRepositories struct {
+	store     *repository.MemoryStore
+	ledger    *repository.MemoryLedgerRepository
+	transfers *repository.MemoryTransferRepository
+}
+
+func newMemoryRepositories(t *testing.T) memoryRepositories {
+	store := repository.NewMemoryStore()
+
+	assets := repository.NewMemoryAssetRepository(store)
+	for _, code := range []string{"USD", "EUR"} {
+		_, err := assets.InsertAsset(model.Asset{Code: code, MinorUnits: 2, RoundingMode: model.HalfEvenRoundingMode})
+		assert.NoError(t, err)
+	}
+
+	return memoryRepositories{
+		store:     store,
+		ledger:    repository.NewMemoryLedgerRepository(store),
+		transfers: repository.NewMemoryTransferRepository(store),
+	}
+}
+
+// claim inserts the transfer into the outbox, and claims it like an outbox worker
+func (r memoryRepositories) claim(t *testing.T, transfer model.Transfer, lease time.Duration) model.Transfer {
+	inserted, err := r.transfers.InsertOutgoingTransfer(transfer)
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+
+	claimed, err := r.transfers.ClaimUnsentTransfers(transfer.ToAsset, 1, lease)
+	assert.NoError(t, err)
+	assert.Len(t, claimed, 1)
+
+	return claimed[0]
+}
+
+func (r memoryRepositories) balance(account string, asset string) decimal.Decimal {
+	balance, _ := r.ledger.GetAccountBalance(account, asset)
+	if balance == nil {
+		return decimal.Zero
+	}
+
+	return balance.Balance
+}
+
+func TestProcessTransferAppliesInternalTransfer(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance(repository.SystemAccount, "EUR", decimal.NewFromInt(1000))
+
+	transfer := r.claim(t, model.Transfer{
+		TransferId:      uuid.New(),
+		CreatedAt:       time.Now().UTC(),
+		FromAsset:       "EUR",
+		ToAsset:         "USD",
+		RequestedAmount: decimal.NewFromInt(100),
+		Fee:             decimal.Zero,
+		Rate:            decimal.RequireFromString("1.1"),
+		Sender:          repository.SystemAccount,
+		Recipient:       repository.SystemAccount,
+		TransferType:    model.InternalTransferType,
+	}, time.Minute)
+
+	assert.NoError(t, service.processTransfer(transfer))
+
+	completed, err := r.transfers.GetTransfer(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.CompletedTransferStatus, completed.TransferStatus)
+	assert.Nil(t, completed.LockId)
+	assert.True(t, decimal.NewFromInt(110).Equal(*completed.SentAmount))
+
+	assert.True(t, decimal.NewFromInt(900).Equal(r.balance(repository.SystemAccount, "EUR")))
+	assert.True(t, decimal.NewFromInt(110).Equal(r.balance(repository.SystemAccount, "USD")))
+
+	events := r.store.OutboxEvents()
+	assert.Len(t, events, 1)
+	assert.Equal(t, event.TransferSentEventType, events[0].EventType)
+}
+
+func TestProcessTransferFailsOnInsufficientBalance(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance("alice", "USD", decimal.NewFromInt(50))
+	assert.NoError(t, r.ledger.InsertNewEntryIfNotExists("USD", repository.SystemAccount))
+
+	transfer := r.claim(t, model.Transfer{
+		TransferId:      uuid.New(),
+		CreatedAt:       time.Now().UTC(),
+		FromAsset:       "USD",
+		ToAsset:         "EUR",
+		RequestedAmount: decimal.NewFromInt(100),
+		Fee:             decimal.NewFromInt(1),
+		Rate:            decimal.RequireFromString("0.9"),
+		Sender:          "alice",
+		Recipient:       "bob",
+		TransferType:    model.ExternalTransferType,
+	}, time.Minute)
+
+	err := service.processTransfer(transfer)
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+
+	// a permanent error fails the transfer on its first attempt
+	failed, err := r.transfers.GetTransfer(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FailedTransferStatus, failed.TransferStatus)
+	assert.Equal(t, 1, failed.AttemptCount)
+	assert.Nil(t, failed.LockId)
+
+	assert.True(t, decimal.NewFromInt(50).Equal(r.balance("alice", "USD")))
+	assert.True(t, r.balance("bob", "EUR").IsZero())
+
+	events := r.store.OutboxEvents()
+	assert.Len(t, events, 1)
+	assert.Equal(t, event.TransferFailedEventType, events[0].EventType)
+}
+
+func TestProcessTransferFailsOnUnknownAsset(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance("alice", "USD", decimal.NewFromInt(100))
+
+	transfer := r.claim(t, model.Transfer{
+		TransferId:      uuid.New(),
+		CreatedAt:       time.Now().UTC(),
+		FromAsset:       "USD",
+		ToAsset:         "XYZ",
+		RequestedAmount: decimal.NewFromInt(100),
+		Fee:             decimal.Zero,
+		Rate:            decimal.RequireFromString("0.123456789"),
+		Sender:          "alice",
+		Recipient:       "bob",
+		TransferType:    model.ExternalTransferType,
+	}, time.Minute)
+
+	// the sent amount cannot be rounded without the asset, so nothing is booked
+	assert.ErrorIs(t, service.processTransfer(transfer), repository.ErrUnknownAsset)
+
+	failed, err := r.transfers.GetTransfer(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.FailedTransferStatus, failed.TransferStatus)
+	assert.True(t, decimal.NewFromInt(100).Equal(r.balance("alice", "USD")))
+}
+
+func TestProcessTransferAfterLockWasReclaimed(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance(repository.SystemAccount, "EUR", decimal.NewFromInt(1000))
+
+	now := time.Now().UTC()
+	r.store.SetClock(func() time.Time { return now })
+
+	transfer := r.claim(t, model.Transfer{
+		TransferId:      uuid.New(),
+		CreatedAt:       now,
+		FromAsset:       "EUR",
+		ToAsset:         "USD",
+		RequestedAmount: decimal.NewFromInt(100),
+		Fee:             decimal.Zero,
+		Rate:            decimal.NewFromInt(1),
+		Sender:          repository.SystemAccount,
+		Recipient:       repository.SystemAccount,
+		TransferType:    model.InternalTransferType,
+	}, time.Second)
+
+	// the worker stalls past its lease, and the lock reaper releases the transfer for another attempt
+	now = now.Add(2 * time.Second)
+	service.reapExpiredLocks()
+
+	released, _ := r.transfers.GetTransfer(transfer.TransferId)
+	assert.Equal(t, model.UnsentTransferStatus, released.TransferStatus)
+	assert.Nil(t, released.LockId)
+
+	// the stalled worker no longer holds the lock, so its attempt is rolled back
+	assert.ErrorIs(t, service.processTransfer(transfer), repository.ErrLockReclaimed)
+
+	unsent, _ := r.transfers.GetTransfer(transfer.TransferId)
+	assert.Equal(t, model.UnsentTransferStatus, unsent.TransferStatus)
+	assert.Nil(t, unsent.SentAt)
+	assert.True(t, decimal.NewFromInt(1000).Equal(r.balance(repository.SystemAccount, "EUR")))
+	assert.True(t, r.balance(repository.SystemAccount, "USD").IsZero())
+	assert.Empty(t, r.store.OutboxEvents())
+
+	// the transfer is claimed again with a new lock
+	reclaimed, err := r.transfers.ClaimUnsentTransfers("USD", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, reclaimed, 1)
+	assert.NotEqual(t, *transfer.LockId, *reclaimed[0].LockId)
+	assert.Equal(t, 2, reclaimed[0].AttemptCount)
+}
+
+func transferCreatedMessage(t *testing.T, request dto.TransferRequest, transferId uuid.UUID, idempotencyKey string) eventbus.Message {
+	created, err := event.NewTransferCreated(request, decimal.Zero, decimal.NewFromInt(1), transferId, idempotencyKey)
+	assert.NoError(t, err)
+
+	value, err := json.Marshal(created)
+	assert.NoError(t, err)
+
+	return eventbus.Message{Topic: TransferTopic, Value: value}
+}
+
+func TestHandleMessageRejectsTransferWithIdempotencyKeyInUse(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance("alice", "USD", decimal.NewFromInt(1000))
+
+	request := dto.TransferRequest{FromAsset: "USD", ToAsset: "USD", Amount: decimal.NewFromInt(100), Sender: "alice", Recipient: "bob"}
+	first := uuid.New()
+	second := uuid.New()
+
+	assert.NoError(t, r.ledger.PlaceHold(model.Hold{TransferId: second, Account: "alice", Asset: "USD", Amount: request.Amount}))
+
+	assert.NoError(t, service.handleMessage(transferCreatedMessage(t, request, first, "key")))
+	assert.NoError(t, service.handleMessage(transferCreatedMessage(t, request, second, "key")))
+
+	// the redelivered event of a recorded transfer is ignored
+	assert.NoError(t, service.handleMessage(transferCreatedMessage(t, request, first, "key")))
+
+	unsent, _ := r.transfers.GetTransfer(first)
+	assert.Equal(t, model.UnsentTransferStatus, unsent.TransferStatus)
+
+	rejected, _ := r.transfers.GetTransfer(second)
+	assert.Equal(t, model.FailedTransferStatus, rejected.TransferStatus)
+	assert.Equal(t, DuplicateIdempotencyKeyReason, *rejected.FailureReason)
+	assert.Nil(t, rejected.IdempotencyKey)
+
+	balance, _ := r.ledger.GetAccountBalance("alice", "USD")
+	assert.True(t, balance.Held.IsZero())
+
+	events := r.store.OutboxEvents()
+	assert.Len(t, events, 1)
+	assert.Equal(t, event.TransferFailedEventType, events[0].EventType)
+
+	// another sender may use the same key
+	other := dto.TransferRequest{FromAsset: "USD", ToAsset: "USD", Amount: decimal.NewFromInt(100), Sender: "carol", Recipient: "bob"}
+	third := uuid.New()
+	assert.NoError(t, service.handleMessage(transferCreatedMessage(t, other, third, "key")))
+
+	accepted, _ := r.transfers.GetTransfer(third)
+	assert.Equal(t, model.UnsentTransferStatus, accepted.TransferStatus)
+}
+
+func TestReverseFailsWhenRecipientSpentTheFunds(t *testing.T) {
+	r := newMemoryRepositories(t)
+	service := NewTransferService(nil, zap.NewNop(), r.transfers, r.ledger, r.store.AssetRegistry(), nil, nil, nil, context.Background(), config.Config{})
+	r.ledger.SetBalance("alice", "USD", decimal.NewFromInt(100))
+	assert.NoError(t, r.ledger.InsertNewEntryIfNotExists("USD", repository.SystemAccount))
+
+	transfer := r.claim(t, model.Transfer{
+		TransferId:      uuid.New(),
+		CreatedAt:       time.Now().UTC(),
+		FromAsset:       "USD",
+		ToAsset:         "EUR",
+		RequestedAmount: decimal.NewFromInt(100),
+		Fee:             decimal.Zero,
+		Rate:            decimal.RequireFromString("0.9"),
+		Sender:          "alice",
+		Recipient:       "bob",
+		TransferType:    model.ExternalTransferType,
+	}, time.Minute)
+
+	_, err := service.sendTransfer(transfer)
+	assert.NoError(t, err)
+
+	// bob spends part of the 90 EUR he received
+	r.ledger.ApplyLedgerEntries(model.LedgerEntry{
+		TransferId: uuid.New(),
+		Account:    "bob",
+		Asset:      "EUR",
+		Amount:     decimal.NewFromInt(-50),
+		Type:       model.TransferLedgerEntryType,
+	})
+
+	settlementService := NewSettlementService(nil, zap.NewNop(), r.transfers, nil, nil, context.Background(), config.Config{})
+
+	reversal, err := settlementService.Reverse(transfer.TransferId, "payout returned")
+	assert.ErrorIs(t, err, repository.ErrReversalNotCovered)
+	assert.Nil(t, reversal)
+
+	// the reversal was rolled back
+	sent, err := r.transfers.GetTransfer(transfer.TransferId)
+	assert.NoError(t, err)
+	assert.Equal(t, model.SentTransferStatus, sent.TransferStatus)
+
+	assert.True(t, r.balance("alice", "USD").IsZero())
+	assert.True(t, decimal.NewFromInt(40).Equal(r.balance("bob", "EUR")))
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"sphere-homework/app/config"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/repository"
+)
+
+// ValidationError is a transfer request rejection that maps to a 4xx response
+type ValidationError struct {
+	Status  int
+	Code    dto.ErrorCode
+	Message string
+}
+
+func (v *ValidationError) Error() string {
+	return v.Message
+}
+
+func newValidationError(status int, code dto.ErrorCode, format string, args ...any) *ValidationError {
+	return &ValidationError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// TransferValidator performs the synchronous checks of a transfer request before it is published
+type TransferValidator struct {
+	assetRepository  repository.AssetRepository
+	ledgerRepository repository.LedgerRepository
+	config           config.Config
+}
+
+func NewTransferValidator(assetRepository repository.AssetRepository, ledgerRepository repository.LedgerRepository, config config.Config) *TransferValidator {
+	return &TransferValidator{
+		assetRepository:  assetRepository,
+		ledgerRepository: ledgerRepository,
+		config:           config,
+	}
+}
+
+// Validate returns a *ValidationError if the request is rejected, or any other error if validation could not be performed
+func (t *TransferValidator) Validate(request dto.TransferRequest) error {
+	if err := validateTransferRequest(request, t.config.TransferMaxAmount); err != nil {
+		return err
+	}
+
+	for _, code := range []string{request.FromAsset, request.ToAsset} {
+		asset, err := t.assetRepository.GetAsset(code)
+		if err != nil {
+			return err
+		}
+
+		if asset == nil {
+			return newValidationError(http.StatusBadRequest, dto.UnknownAssetErrorCode, "unknown asset: %s", code)
+		}
+
+		if !asset.Enabled {
+			return newValidationError(http.StatusBadRequest, dto.DisabledAssetErrorCode, "asset is disabled: %s", code)
+		}
+
+		// compares the values rather than the exponent, so trailing zeros like 10.500 for USD are accepted
+		if code == request.FromAsset && !request.Amount.Equal(request.Amount.Truncate(asset.MinorUnits)) {
+			return newValidationError(http.StatusBadRequest, dto.InvalidAmountErrorCode, "amount has more than %d decimal places for %s", asset.MinorUnits, code)
+		}
+	}
+
+	balance, err := t.ledgerRepository.GetAccountBalance(request.Sender, request.FromAsset)
+	if err != nil {
+		return err
+	}
+
+	if balance == nil {
+		return newValidationError(http.StatusUnprocessableEntity, dto.UnknownSenderErrorCode, "sender %s has no %s account", request.Sender, request.FromAsset)
+	}
+
+	// transfers still in the outbox are not yet debited from the ledger
+	if balance.Available.LessThan(request.Amount) {
+		return newValidationError(http.StatusUnprocessableEntity, dto.InsufficientBalanceErrorCode, "not enough %s balance for transfer", request.FromAsset)
+	}
+
+	return nil
+}
+
+// validateTransferRequest performs the checks that do not need to hit the db
+func validateTransferRequest(request dto.TransferRequest, maxAmount decimal.Decimal) *ValidationError {
+	if request.Sender == "" || request.Recipient == "" || request.FromAsset == "" || request.ToAsset == "" {
+		return newValidationError(http.StatusBadRequest, dto.InvalidRequestErrorCode, "sender, recipient, from_asset and to_asset are required")
+	}
+
+	if request.Sender == request.Recipient {
+		return newValidationError(http.StatusBadRequest, dto.SameSenderRecipientErrorCode, "sender and recipient must be different")
+	}
+
+	if !request.Amount.IsPositive() {
+		return newValidationError(http.StatusBadRequest, dto.InvalidAmountErrorCode, "amount must be positive")
+	}
+
+	if maxAmount.IsPositive() && request.Amount.GreaterThan(maxAmount) {
+		return newValidationError(http.StatusBadRequest, dto.AmountLimitExceededErrorCode, "amount exceeds the maximum of %s", maxAmount.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"sphere-homework/app/config"
+	"sphere-homework/app/dto"
+	"sphere-homework/app/model"
+	"sphere-homework/app/repository"
+	"testing"
+)
+
+func newTransferRequest(amount string) dto.TransferRequest {
+	return dto.TransferRequest{
+		FromAsset: "USD",
+		ToAsset:   "GBP",
+		Amount:    decimal.RequireFromString(amount),
+		Sender:    "jim",
+		Recipient: "jacob",
+	}
+}
+
+func TestValidateTransferRequestAcceptsValidRequest(t *testing.T) {
+	err := validateTransferRequest(newTransferRequest("100.25"), decimal.NewFromInt(1000))
+
+	assert.Nil(t, err)
+}
+
+func TestValidateTransferRequestRejectsNonPositiveAmount(t *testing.T) {
+	for _, amount := range []string{"0", "-10"} {
+		err := validateTransferRequest(newTransferRequest(amount), decimal.Zero)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, dto.InvalidAmountErrorCode, err.Code)
+	}
+}
+
+func TestValidateTransferRequestRejectsAmountOverLimit(t *testing.T) {
+	err := validateTransferRequest(newTransferRequest("1000.01"), decimal.NewFromInt(1000))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, dto.AmountLimitExceededErrorCode, err.Code)
+}
+
+func TestValidateTransferRequestRejectsSameSenderAndRecipient(t *testing.T) {
+	request := newTransferRequest("10")
+	request.Recipient = request.Sender
+
+	err := validateTransferRequest(request, decimal.Zero)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, dto.SameSenderRecipientErrorCode, err.Code)
+}
+
+func TestValidateTransferRequestRejectsMissingFields(t *testing.T) {
+	request := newTransferRequest("10")
+	request.ToAsset = ""
+
+	err := validateTransferRequest(request, decimal.Zero)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, dto.InvalidRequestErrorCode, err.Code)
+}
+
+func TestValidateChecksDecimalPlacesOfTheSourceAsset(t *testing.T) {
+	store := repository.NewMemoryStore()
+
+	assets := repository.NewMemoryAssetRepository(store)
+	for _, code := range []string{"USD", "GBP"} {
+		_, err := assets.InsertAsset(model.Asset{Code: code, MinorUnits: 2, RoundingMode: model.HalfEvenRoundingMode})
+		assert.NoError(t, err)
+	}
+
+	ledger := repository.NewMemoryLedgerRepository(store)
+	ledger.SetBalance("jim", "USD", decimal.NewFromInt(1000))
+
+	validator := NewTransferValidator(assets, ledger, config.Config{})
+
+	// trailing zeros do not add precision
+	assert.NoError(t, validator.Validate(newTransferRequest("100.2500")))
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, validator.Validate(newTransferRequest("100.255")), &validationErr)
+	assert.Equal(t, dto.InvalidAmountErrorCode, validationErr.Code)
+}
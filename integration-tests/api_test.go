@@ -3,9 +3,11 @@ package integration_tests
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/shopspring/decimal"
 	"io"
 	"net/http"
 	"sphere-homework/app/config"
@@ -24,7 +26,7 @@ var _ = Describe("Testing API endpoints", func() {
 			request := dto.TransferRequest{
 				FromAsset: "USD",
 				ToAsset:   "GBP",
-				Amount:    30000,
+				Amount:    decimal.NewFromInt(30),
 				Sender:    "jim",
 				Recipient: "system",
 			}
@@ -47,5 +49,70 @@ var _ = Describe("Testing API endpoints", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response.TransferId).To(Not(BeNil()))
 		})
+
+		It("returns an error code when the sender has not enough balance", func() {
+			request := dto.TransferRequest{
+				FromAsset: "USD",
+				ToAsset:   "GBP",
+				Amount:    decimal.NewFromInt(30000),
+				Sender:    "jim",
+				Recipient: "system",
+			}
+
+			b, err := json.Marshal(request)
+			Expect(err).NotTo(HaveOccurred())
+			reader := strings.NewReader(string(b))
+
+			resp, err := client.Post(baseUrl+"/transfer", "application/json", reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := dto.ErrorResponse{}
+			err = json.Unmarshal(body, &response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Code).To(Equal(dto.InsufficientBalanceErrorCode))
+		})
+	})
+
+	When("/transfer/{id} endpoint is invoked", func() {
+		It("returns not found for an unknown transfer", func() {
+			resp, err := client.Get(baseUrl + "/transfer/" + uuid.New().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := dto.ErrorResponse{}
+			err = json.Unmarshal(body, &response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Code).To(Equal(dto.TransferNotFoundErrorCode))
+		})
+	})
+
+	When("/accounts/{account}/balances endpoint is invoked", func() {
+		It("returns the balances of the account", func() {
+			resp, err := client.Get(baseUrl + "/accounts/jim/balances")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := dto.AccountBalancesResponse{}
+			err = json.Unmarshal(body, &response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Account).To(Equal("jim"))
+			Expect(response.Balances).NotTo(BeEmpty())
+		})
 	})
 })
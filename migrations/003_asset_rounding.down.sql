@@ -0,0 +1,6 @@
+BEGIN;
+
+-- enum values cannot be dropped, only the rounding account is removed
+DELETE FROM ledger WHERE account_name = 'system_rounding';
+
+COMMIT;
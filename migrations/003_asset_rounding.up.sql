@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TYPE ledger_entry_type ADD VALUE IF NOT EXISTS 'ROUNDING';
+
+-- Rounding account that collects the remainders of rounding sent amounts to the asset's minor unit
+INSERT INTO ledger (account_name, balance, asset)
+VALUES
+    ('system_rounding', '0', 'USD'),
+    ('system_rounding', '0', 'EUR'),
+    ('system_rounding', '0', 'JPY'),
+    ('system_rounding', '0', 'GBP'),
+    ('system_rounding', '0', 'AUD')
+ON CONFLICT (account_name, asset) DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS asset (
+    code VARCHAR NOT NULL PRIMARY KEY,
+    minor_units INTEGER NOT NULL CHECK (minor_units >= 0 AND minor_units <= 30),
+    rounding_mode VARCHAR NOT NULL DEFAULT 'HALF_EVEN',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO asset (code, minor_units, rounding_mode)
+VALUES
+    ('USD', 2, 'HALF_EVEN'),
+    ('EUR', 2, 'HALF_EVEN'),
+    ('JPY', 0, 'HALF_EVEN'),
+    ('GBP', 2, 'HALF_EVEN'),
+    ('AUD', 2, 'HALF_EVEN')
+ON CONFLICT (code) DO NOTHING;
+
+COMMIT;
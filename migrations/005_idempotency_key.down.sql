@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__idempotency_key;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS idempotency_key;
+DROP TABLE IF EXISTS idempotency_key;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    idempotency_key VARCHAR NOT NULL PRIMARY KEY,
+    sender VARCHAR NOT NULL,
+    request_hash VARCHAR NOT NULL,
+    transfer_id UUID NOT NULL,
+    response JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- a transfer_created event carrying an already recorded idempotency key is ignored by the outbox
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR;
+CREATE UNIQUE INDEX IF NOT EXISTS outgoing_transfer__idempotency_key ON outgoing_transfer(idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS ledger_history__transfer_id;
+
+COMMIT;
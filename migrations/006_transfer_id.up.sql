@@ -0,0 +1,7 @@
+BEGIN;
+
+-- outgoing_transfer.transfer_id is the primary key, so a redelivered transfer_created event is a no-op.
+-- ledger_history is looked up by the same transfer id.
+CREATE INDEX IF NOT EXISTS ledger_history__transfer_id ON ledger_history(transfer_id);
+
+COMMIT;
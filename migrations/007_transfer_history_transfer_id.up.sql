@@ -0,0 +1,6 @@
+BEGIN;
+
+-- transfer status lookups of transfers that are accepted but not yet in the outbox
+CREATE INDEX IF NOT EXISTS transfer_history__transfer_id ON transfer_history((event->>'transfer_id'));
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__sender_created_at;
+DROP INDEX IF EXISTS outgoing_transfer__recipient_created_at;
+
+COMMIT;
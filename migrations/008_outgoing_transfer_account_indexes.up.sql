@@ -0,0 +1,7 @@
+BEGIN;
+
+-- account transfer listing, ordered by created_at, transfer_id descending
+CREATE INDEX IF NOT EXISTS outgoing_transfer__sender_created_at ON outgoing_transfer(sender, created_at DESC, transfer_id DESC);
+CREATE INDEX IF NOT EXISTS outgoing_transfer__recipient_created_at ON outgoing_transfer(recipient, created_at DESC, transfer_id DESC);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS hold;
+ALTER TABLE ledger DROP COLUMN IF EXISTS held;
+DROP TYPE IF EXISTS hold_status;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TYPE hold_status AS ENUM ('HELD', 'CAPTURED', 'RELEASED');
+
+-- sum of the active holds of the account's asset, the available balance is balance - held
+ALTER TABLE ledger ADD COLUMN IF NOT EXISTS held NUMERIC(40, 30) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS hold (
+    transfer_id UUID NOT NULL PRIMARY KEY,
+    account VARCHAR NOT NULL,
+    asset VARCHAR NOT NULL,
+    amount NUMERIC(40, 30) NOT NULL,
+    status hold_status NOT NULL DEFAULT 'HELD',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS hold__account_asset ON hold(account, asset) WHERE status = 'HELD';
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__sent_at;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS settled_at;
+
+COMMIT;
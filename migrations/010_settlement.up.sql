@@ -0,0 +1,9 @@
+BEGIN;
+
+-- time the rail confirmed (COMPLETED) or rejected (FAILED) a sent transfer
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS settled_at TIMESTAMP WITH TIME ZONE;
+
+-- used by the settlement sweeper to find sent transfers that were never confirmed
+CREATE INDEX IF NOT EXISTS outgoing_transfer__sent_at ON outgoing_transfer(sent_at) WHERE status = 'SENT';
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+-- enum values cannot be dropped, reversal entries are kept in the ledger history
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- compensating entries booked against the same transfer_id when a transfer is reversed
+ALTER TYPE ledger_entry_type ADD VALUE IF NOT EXISTS 'REVERSAL';
+
+COMMIT;
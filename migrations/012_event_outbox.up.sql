@@ -0,0 +1,15 @@
+BEGIN;
+
+-- events written in the same transaction as the state change they describe, published to kafka by the event relay
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    event_type VARCHAR NOT NULL,
+    sender VARCHAR NOT NULL,
+    event JSONB NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS event_outbox__unpublished ON event_outbox(id) WHERE published_at IS NULL;
+
+COMMIT;
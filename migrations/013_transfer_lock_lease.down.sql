@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__lock_expires_at;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS lock_expires_at;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS locked_at;
+
+COMMIT;
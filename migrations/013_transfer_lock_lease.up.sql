@@ -0,0 +1,12 @@
+BEGIN;
+
+-- outbox processor locks are leases - locks that expire are reclaimed by the lock reaper
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS lock_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- locks taken before leases existed expire right away
+UPDATE outgoing_transfer SET locked_at = NOW(), lock_expires_at = NOW() WHERE lock_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS outgoing_transfer__lock_expires_at ON outgoing_transfer(lock_expires_at) WHERE lock_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__unsent_to_asset;
+
+COMMIT;
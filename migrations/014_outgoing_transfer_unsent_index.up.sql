@@ -0,0 +1,6 @@
+BEGIN;
+
+-- outbox workers claim the oldest unsent transfers of their destination asset
+CREATE INDEX IF NOT EXISTS outgoing_transfer__unsent_to_asset ON outgoing_transfer(to_asset, created_at) WHERE status = 'UNSENT' AND lock_id IS NULL;
+
+COMMIT;
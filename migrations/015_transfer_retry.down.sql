@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS last_error;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE outgoing_transfer DROP COLUMN IF EXISTS attempt_count;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- transient failures are retried with backoff, a transfer is claimed again once next_attempt_at passed
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS attempt_count INT NOT NULL DEFAULT 0;
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE outgoing_transfer ADD COLUMN IF NOT EXISTS last_error VARCHAR;
+
+COMMIT;
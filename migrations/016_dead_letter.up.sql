@@ -0,0 +1,21 @@
+BEGIN;
+
+-- messages a consumer failed to decode or process, recorded from the dead-letter topic so they can be listed and replayed
+CREATE TABLE IF NOT EXISTS dead_letter (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    consumer VARCHAR NOT NULL,
+    error VARCHAR NOT NULL,
+    source_topic VARCHAR NOT NULL,
+    source_partition INT NOT NULL,
+    source_offset BIGINT NOT NULL,
+    message_key BYTEA,
+    payload BYTEA NOT NULL,
+    headers JSONB NOT NULL DEFAULT '[]',
+    replayed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (consumer, source_topic, source_partition, source_offset)
+);
+
+CREATE INDEX IF NOT EXISTS dead_letter__pending ON dead_letter(id) WHERE replayed_at IS NULL;
+
+COMMIT;
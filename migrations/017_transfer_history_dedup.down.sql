@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS transfer_history__event;
+
+COMMIT;
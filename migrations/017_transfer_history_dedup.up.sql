@@ -0,0 +1,13 @@
+BEGIN;
+
+-- consumers commit their offsets after handling a message, so an event can be redelivered - drop the copies recorded so far
+DELETE FROM transfer_history duplicate
+USING transfer_history original
+WHERE duplicate.ctid > original.ctid
+AND duplicate.event_type = original.event_type
+AND duplicate.created_at = original.created_at
+AND duplicate.event = original.event;
+
+CREATE UNIQUE INDEX IF NOT EXISTS transfer_history__event ON transfer_history(event_type, created_at, md5(event::text));
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS outgoing_transfer__sender_idempotency_key;
+CREATE UNIQUE INDEX IF NOT EXISTS outgoing_transfer__idempotency_key ON outgoing_transfer(idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+ALTER TABLE idempotency_key DROP CONSTRAINT IF EXISTS idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (idempotency_key);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- idempotency keys are chosen by the clients, so two senders may pick the same key
+ALTER TABLE idempotency_key DROP CONSTRAINT IF EXISTS idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (sender, idempotency_key);
+
+-- an outbox row only carries the idempotency key while the key is reserved for its transfer - a key reserved again
+-- once it expired is taken off the previous transfer's row, so the new transfer is not mistaken for a duplicate
+DROP INDEX IF EXISTS outgoing_transfer__idempotency_key;
+CREATE UNIQUE INDEX IF NOT EXISTS outgoing_transfer__sender_idempotency_key ON outgoing_transfer(sender, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+COMMIT;
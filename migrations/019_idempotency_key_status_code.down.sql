@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE idempotency_key DROP COLUMN IF EXISTS status_code;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- status code of the response replayed on retries, e.g. 202 if the delivery of the transfer event was not confirmed
+ALTER TABLE idempotency_key ADD COLUMN IF NOT EXISTS status_code INT NOT NULL DEFAULT 201;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS claimed_until;
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS claim_id;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- the event relay claims a batch of events with a lease and publishes it outside of the claiming transaction - no other
+-- relay claims events while a batch is claimed, so the events are published in order
+ALTER TABLE event_outbox ADD COLUMN IF NOT EXISTS claim_id UUID;
+ALTER TABLE event_outbox ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS pool_rebalance_setting;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+-- per-asset settings of the system pool rebalancer, assets without settings are not rebalanced
+CREATE TABLE IF NOT EXISTS pool_rebalance_setting (
+    asset VARCHAR NOT NULL PRIMARY KEY REFERENCES asset (code),
+    imbalance_threshold DOUBLE PRECISION NOT NULL,
+    minimum_balance NUMERIC(40, 30) NOT NULL,
+    top_up_amount NUMERIC(40, 30) NOT NULL,
+    required_balance_for_top_up NUMERIC(40, 30) NOT NULL DEFAULT 0
+);
+
+INSERT INTO pool_rebalance_setting (asset, imbalance_threshold, minimum_balance, top_up_amount)
+VALUES
+    ('USD', 0.7, 400000, 15000),
+    ('EUR', 0.2, 5000, 10000),
+    ('JPY', 0.3, 500000, 700000),
+    ('GBP', 0.1, 100000, 120000),
+    ('AUD', 0.2, 300000, 320000)
+ON CONFLICT (asset) DO NOTHING;
+
+COMMIT;